@@ -1,9 +1,62 @@
 package api
 
-import "embed"
+import (
+	"crypto/sha256"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
 
 //go:embed static/*
 var assetsFS embed.FS
 
 //go:embed templates/*
 var templatesFS embed.FS
+
+// assetManifest maps static files to content-hashed names, hashed names are safe to cache forever
+type assetManifest struct {
+	hashed map[string]string // original name -> hashed name
+	orig   map[string]string // hashed name -> original name
+}
+
+// newAssetManifest hashes all files of fsys under the root dir
+func newAssetManifest(fsys fs.FS, root string) (*assetManifest, error) {
+	res := &assetManifest{hashed: map[string]string{}, orig: map[string]string{}}
+	err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(p, root+"/")
+		ext := path.Ext(name)
+		hash := fmt.Sprintf("%x", sha256.Sum256(data))[:12]
+		hashedName := fmt.Sprintf("%s.%s%s", strings.TrimSuffix(name, ext), hash, ext)
+		res.hashed[name] = hashedName
+		res.orig[hashedName] = name
+		return nil
+	})
+	return res, err
+}
+
+// url returns absolute url of the hashed asset, template function "asset"
+func (m *assetManifest) url(name string) (string, error) {
+	hashedName, ok := m.hashed[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return "", fmt.Errorf("unknown asset %q", name)
+	}
+	return "/" + hashedName, nil
+}
+
+// resolve maps requested path to the original file name, returns true for hashed (immutable) names
+func (m *assetManifest) resolve(requestedPath string) (name string, immutable bool) {
+	name = strings.TrimPrefix(requestedPath, "/")
+	if orig, ok := m.orig[name]; ok {
+		return orig, true
+	}
+	return name, false
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+)
+
+const sseKeepAlive = 30 * time.Second
+
+// eventData is a payload of the "item" server-sent event
+type eventData struct {
+	Feed     string   `json:"feed"`
+	Title    string   `json:"title"`
+	Link     string   `json:"link"`
+	Source   string   `json:"source,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Score    float64  `json:"score"`
+	Audio    string   `json:"audio,omitempty"`
+	Duration string   `json:"duration,omitempty"`
+	DT       string   `json:"dt"`
+	TS       int64    `json:"ts"`
+}
+
+// GET /events and /events/{name} - server-sent events stream with new items, for all feeds or the given one
+func (s *Server) getEventsCtrl(w http.ResponseWriter, r *http.Request) {
+	if s.Events == nil {
+		s.renderErrorPage(w, r, errors.New("live updates disabled"), http.StatusNotFound)
+		return
+	}
+
+	feedName := chi.URLParam(r, "name")
+	if _, ok := s.Conf.Feeds[feedName]; feedName != "" && !ok {
+		s.renderErrorPage(w, r, errors.Errorf("unknown feed %q", feedName), http.StatusNotFound)
+		return
+	}
+
+	// stream lives longer than server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("[DEBUG] can't reset write deadline for events, %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("[WARN] events stream not supported, %v", err)
+		return
+	}
+
+	sub := s.Events.Subscribe(feedName)
+	defer s.Events.Unsubscribe(sub)
+	log.Printf("[DEBUG] events subscriber connected, feed=%q, from %s", feedName, r.RemoteAddr)
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case evt, ok := <-sub.C:
+			if !ok {
+				return // disconnected as a slow subscriber, client will reconnect
+			}
+			if evt.Hidden {
+				continue
+			}
+			duration := ""
+			if d, e := time.ParseDuration(evt.Item.Duration + "s"); e == nil && evt.Item.Duration != "" {
+				duration = d.String()
+			}
+			data, err := json.Marshal(eventData{
+				Feed:     evt.Feed,
+				Title:    evt.Item.Title,
+				Link:     evt.Item.Link,
+				Source:   evt.Item.Source,
+				Tags:     evt.Item.Tags,
+				Score:    evt.Item.Score,
+				Audio:    evt.Item.Enclosure.URL,
+				Duration: duration,
+				DT:       evt.Item.DT.Format("02 Jan 15:04"),
+				TS:       evt.Item.DT.Unix(),
+			})
+			if err != nil {
+				log.Printf("[WARN] can't marshal event, %v", err)
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "event: item\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
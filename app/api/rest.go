@@ -0,0 +1,142 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-pkgz/rest"
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/search"
+)
+
+const defaultSearchLimit = 50
+
+// GET /api/v1/search?q=text&feed=name&from=2006-01-02&to=2006-01-02&limit=50 - full-text search over stored items.
+// Quoted parts of q are phrases, feed can be repeated.
+func (s *Server) getSearchCtrl(w http.ResponseWriter, r *http.Request) {
+	q, err := s.searchQuery(r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, rest.JSON{"error": err.Error()})
+		return
+	}
+
+	results, err := s.Store.Search(q)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, rest.JSON{"error": err.Error()})
+		return
+	}
+
+	render.JSON(w, r, rest.JSON{"query": r.URL.Query().Get("q"), "total": len(results), "items": results})
+}
+
+// searchQuery makes search query from request parameters
+func (s *Server) searchQuery(r *http.Request) (search.Query, error) {
+	params := r.URL.Query()
+	q := search.ParseQuery(params.Get("q"))
+	if q.Empty() {
+		return q, errors.New("empty search query")
+	}
+
+	q.Feeds = params["feed"]
+	var err error
+	if v := params.Get("from"); v != "" {
+		if q.From, err = search.ParseDate(v); err != nil {
+			return q, errors.Wrapf(err, "bad from date %q", v)
+		}
+	}
+	if v := params.Get("to"); v != "" {
+		if q.To, err = search.ParseEndDate(v); err != nil {
+			return q, errors.Wrapf(err, "bad to date %q", v)
+		}
+	}
+
+	q.Limit = defaultSearchLimit
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+			return q, errors.Errorf("bad limit %q", v)
+		}
+	}
+	return q, nil
+}
+
+// GET /api/v1/feed/{name}?tag=name&source=name&q=text&from=2006-01-02&to=2006-01-02&hide_junk=1&page=2 - items
+// of the feed, same parameters and feed names as for the feed page
+func (s *Server) getFeedItemsCtrl(w http.ResponseWriter, r *http.Request) {
+	fs, err := s.feedSet(chi.URLParam(r, "name"))
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, rest.JSON{"error": err.Error()})
+		return
+	}
+	fq, err := s.feedPageQuery(r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, rest.JSON{"error": err.Error()})
+		return
+	}
+
+	items, more, err := s.loadPage(fs, fq.Query)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, rest.JSON{"error": err.Error()})
+		return
+	}
+	render.JSON(w, r, rest.JSON{"feed": fs.name, "page": fq.page, "more": more, "items": items})
+}
+
+// GET /api/v1/status - telegram delivery state, pending and dead outbox entries in total and per feed,
+// rate limit budgets, global and per chat
+func (s *Server) getStatusCtrl(w http.ResponseWriter, r *http.Request) {
+	outbox, err := s.Store.OutboxStats()
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, rest.JSON{"error": err.Error()})
+		return
+	}
+	res := rest.JSON{"outbox": outbox}
+	if s.Limiter != nil {
+		res["rate_limit"] = s.Limiter.State()
+	}
+	render.JSON(w, r, res)
+}
+
+// GET /api/v1/settings - effective limits of all feeds and sources, resolved from system, feed and source levels
+func (s *Server) getSettingsCtrl(w http.ResponseWriter, r *http.Request) {
+	type limits struct {
+		MaxAge      string `json:"max_age"`
+		MaxPerFetch int    `json:"max_per_fetch"`
+		MaxKeep     int    `json:"max_keep"`
+		Update      string `json:"update"`
+	}
+	toJSON := func(l config.Limits) limits {
+		return limits{MaxAge: l.MaxAge.String(), MaxPerFetch: l.MaxPerFetch, MaxKeep: l.MaxKeep, Update: l.Update.String()}
+	}
+
+	type feedSettings struct {
+		Limits  limits            `json:"limits"`
+		Sources map[string]limits `json:"sources"`
+	}
+	feeds := map[string]feedSettings{}
+	for name, fc := range s.Conf.Feeds {
+		fs := feedSettings{Limits: toJSON(s.Conf.FeedLimits(name)), Sources: map[string]limits{}}
+		for _, src := range fc.Sources {
+			fs.Sources[src.Name] = toJSON(s.Conf.SourceLimits(name, src))
+		}
+		feeds[name] = fs
+	}
+
+	render.JSON(w, r, rest.JSON{
+		"system": rest.JSON{
+			"max_total":             s.Conf.System.MaxTotal,
+			"concurrent":            s.Conf.System.Concurrent,
+			"http_response_timeout": s.Conf.System.HTTPResponseTimeout.String(),
+		},
+		"feeds": feeds,
+	})
+}
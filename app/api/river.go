@@ -0,0 +1,112 @@
+package api
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// allFeeds is a pseudo-feed name for all configured feeds merged together
+const allFeeds = "_all"
+
+// feedSet is a single feed or a number of feeds merged in time order, like "_all" or "go+rust"
+type feedSet struct {
+	name  string   // requested name
+	names []string // resolved names of the feeds
+}
+
+// merged checks if the set made of multiple feeds
+func (fs feedSet) merged() bool {
+	return fs.name == allFeeds || len(fs.names) > 1
+}
+
+// feedSet resolves requested feed name to the set of configured feeds
+func (s *Server) feedSet(name string) (feedSet, error) {
+	if name == allFeeds {
+		names := s.feeds()
+		sort.Strings(names)
+		return feedSet{name: name, names: names}, nil
+	}
+
+	res := feedSet{name: name}
+	for _, n := range strings.Split(name, "+") {
+		if _, ok := s.Conf.Feeds[n]; !ok && strings.Contains(name, "+") {
+			return res, errors.Errorf("unknown feed %q", n)
+		}
+		res.names = append(res.names, n)
+	}
+	return res, nil
+}
+
+// feedSetConf returns feed config, for merged sets it's made from configs of all feeds
+func (s *Server) feedSetConf(fs feedSet) config.Feed {
+	if !fs.merged() {
+		return s.Conf.Feeds[fs.names[0]]
+	}
+
+	res := config.Feed{Title: strings.Join(fs.names, " + ")}
+	if fs.name == allFeeds {
+		res.Title = "All feeds"
+	}
+	titles := make([]string, 0, len(fs.names))
+	for _, n := range fs.names {
+		fc := s.Conf.Feeds[n]
+		titles = append(titles, fc.Title)
+		res.Sources = append(res.Sources, fc.Sources...)
+		for t, m := range fc.Tags {
+			if res.Tags == nil {
+				res.Tags = config.Tags{}
+			}
+			res.Tags[t] = m
+		}
+		res.Scoring.Enabled = res.Scoring.Enabled || fc.Scoring.Enabled
+		if res.Language == "" {
+			res.Language = fc.Language
+		}
+	}
+	res.Description = strings.Join(titles, ", ")
+	res.Link = s.Conf.System.BaseURL + "/feed/" + fs.name
+	return res
+}
+
+// loadPage loads page of items for the feed set. Items of merged feeds are tagged with the feed name,
+// and junk is always skipped for them.
+func (s *Server) loadPage(fs feedSet, q feed.Query) ([]feed.Item, bool, error) {
+	if !fs.merged() {
+		return s.Store.LoadPage(fs.names[0], q)
+	}
+
+	// load everything up to the end of the page from each feed and merge by time
+	perFeed := q
+	perFeed.SkipJunk = true
+	perFeed.Offset = 0
+	perFeed.Limit = q.Offset + q.Limit
+
+	var items []feed.Item
+	more := false
+	for _, name := range fs.names {
+		feedItems, feedMore, err := s.Store.LoadPage(name, perFeed)
+		if err != nil {
+			continue // feed may have no items yet
+		}
+		for i := range feedItems {
+			feedItems[i].Feed = name
+		}
+		items = append(items, feedItems...)
+		more = more || feedMore
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DT.After(items[j].DT) })
+	if len(items) <= q.Offset {
+		return nil, more, nil
+	}
+	items = items[q.Offset:]
+	if len(items) > q.Limit {
+		items, more = items[:q.Limit], true
+	}
+	return items, more, nil
+}
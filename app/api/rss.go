@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// GET /rss/{name}?tag=name - renders rss feed, name can be "_all" for all feeds or combination like "go+rust".
+// Items of merged feeds have the feed name as the first category, item tags are added as categories too.
+func (s *Server) getRSSCtrl(w http.ResponseWriter, r *http.Request) {
+	if s.cache == nil {
+		s.renderErrorPage(w, r, errors.New("cache not initialized"), 500)
+		return
+	}
+
+	fs, err := s.feedSet(chi.URLParam(r, "name"))
+	if err != nil {
+		s.renderErrorPage(w, r, err, 400)
+		return
+	}
+
+	tag := strings.ToLower(r.URL.Query().Get("tag"))
+	data, err := s.cache.Get("rss/"+fs.name+"?tag="+tag, func() ([]byte, error) {
+		limit := s.Conf.System.MaxTotal
+		if limit <= 0 {
+			limit = defaultPageSize
+		}
+		items, _, err := s.loadPage(fs, feed.Query{Limit: limit, SkipJunk: true, Tag: tag})
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range items {
+			if items[i].Feed != "" {
+				items[i].Categories = append([]string{items[i].Feed}, items[i].Categories...)
+			}
+			items[i].Categories = append(items[i].Categories, items[i].Tags...)
+		}
+
+		fc := s.feedSetConf(fs)
+		rss := feed.Rss2{
+			Version:        "2.0",
+			NsItunes:       "http://www.itunes.com/dtds/podcast-1.0.dtd",
+			NsMedia:        "http://search.yahoo.com/mrss/",
+			Title:          fc.Title,
+			Language:       fc.Language,
+			Link:           fc.Link,
+			Description:    fc.Description,
+			LastBuildDate:  time.Now().Format(time.RFC1123Z),
+			ItunesAuthor:   fc.Author,
+			ItunesExplicit: "no",
+			ItemList:       items,
+		}
+		if len(items) > 0 {
+			rss.PubDate = items[0].PubDate
+		}
+		if fc.Image != "" {
+			rss.ItunesImage = &feed.ItunesImg{URL: fc.Image}
+			rss.MediaThumbnail = &feed.MediaThumbnail{URL: fc.Image}
+		}
+		if fc.OwnerEmail != "" {
+			rss.ItunesOwner = &feed.ItunesOwner{Email: fc.OwnerEmail, Name: fc.Author}
+		}
+
+		b, err := xml.MarshalIndent(&rss, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), b...), nil
+	})
+	if err != nil {
+		s.renderErrorPage(w, r, err, 400)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"mime"
 	"net/http"
 	"path"
@@ -22,23 +23,37 @@ import (
 
 	"github.com/umputun/feed-master/app/config"
 	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/proc"
+	"github.com/umputun/feed-master/app/pubsub"
+	"github.com/umputun/feed-master/app/search"
 )
 
 // Server provides HTTP API
 type Server struct {
-	Store Store
-	cache lcw.LoadingCache[[]byte]
+	Store   Store
+	Events  *pubsub.Broker // optional, live updates disabled if nil
+	Limiter RateLimiter    // optional
+	cache   lcw.LoadingCache[[]byte]
 
 	httpServer    *http.Server
 	templates     *template.Template
+	assets        *assetManifest
 	Version       string
 	TemplLocation string
 	Conf          config.Conf
 }
 
+// RateLimiter provides state of telegram rate limits
+type RateLimiter interface {
+	State() proc.RateLimitState
+}
+
 // Store provides access to feed data
 type Store interface {
 	Load(fmFeed string, max int, skipJunk bool) ([]feed.Item, error)
+	LoadPage(fmFeed string, q feed.Query) ([]feed.Item, bool, error)
+	Search(q search.Query) ([]search.Result, error)
+	OutboxStats() (proc.OutboxStats, error)
 }
 
 // Run starts http server for API with all routes
@@ -51,6 +66,10 @@ func (s *Server) Run(ctx context.Context, port int) {
 		return
 	}
 
+	if s.Events != nil {
+		go s.purgeCacheOnEvents(ctx)
+	}
+
 	serverLock := sync.Mutex{}
 	go func() {
 		<-ctx.Done()
@@ -63,8 +82,13 @@ func (s *Server) Run(ctx context.Context, port int) {
 		}
 	}()
 
+	if s.assets, err = newAssetManifest(assetsFS, "static"); err != nil {
+		log.Printf("[ERROR] failed to hash assets, %v", err)
+		return
+	}
+
 	// Parse the templates from the embedded file system
-	tmpl, err := template.ParseFS(templatesFS, "templates/*")
+	tmpl, err := template.New("").Funcs(template.FuncMap{"asset": s.assets.url}).ParseFS(templatesFS, "templates/*")
 	if err != nil {
 		log.Printf("[ERROR] failed to parse templates, %v", err)
 		return
@@ -89,6 +113,7 @@ func (s *Server) Run(ctx context.Context, port int) {
 
 func (s *Server) router() *chi.Mux {
 	router := chi.NewRouter()
+	router.Use(securityHeaders)
 
 	router.Group(func(r chi.Router) {
 		l := logger.New(
@@ -99,19 +124,73 @@ func (s *Server) router() *chi.Mux {
 		r.Use(l.Handler)
 
 		r.Get("/feed/{name}", s.getFeedPageCtrl)
+		r.Get("/rss/{name}", s.getRSSCtrl)
 		r.Get("/feeds", s.getFeedsPageCtrl)
+		r.Get("/search", s.getSearchPageCtrl)
+	})
+
+	// server-sent events are long-living, not logged per request
+	router.Get("/events", s.getEventsCtrl)
+	router.Get("/events/{name}", s.getEventsCtrl)
+
+	router.Route("/api/v1", func(r chi.Router) {
+		r.Use(logger.New(logger.Log(log.Default()), logger.Prefix("[DEBUG]")).Handler)
+		r.Get("/search", s.getSearchCtrl)
+		r.Get("/feed/{name}", s.getFeedItemsCtrl)
+		r.Get("/settings", s.getSettingsCtrl)
+		r.Get("/status", s.getStatusCtrl)
 	})
 
 	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
-		err := tryRead(assetsFS, "static", r.URL.Path, w)
+		name := r.URL.Path
+		if s.assets != nil {
+			var immutable bool
+			if name, immutable = s.assets.resolve(name); immutable {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			}
+		}
+		err := tryRead(assetsFS, "static", name, w)
 		if err == nil {
 			return
 		}
+		if errors.Is(err, fs.ErrNotExist) {
+			w.Header().Del("Cache-Control")
+			http.NotFound(w, r)
+		}
 	})
 
 	return router
 }
 
+// purgeCacheOnEvents drops all cached pages on each new item, so pages are never stale
+func (s *Server) purgeCacheOnEvents(ctx context.Context) {
+	for {
+		sub := s.Events.Subscribe("")
+		for open := true; open; {
+			select {
+			case <-ctx.Done():
+				s.Events.Unsubscribe(sub)
+				return
+			case _, open = <-sub.C:
+				s.cache.Purge()
+			}
+		}
+		// disconnected as a slow subscriber, some events were missed and already purged above
+	}
+}
+
+// securityHeaders sets strict content security policy, all assets are served locally
+func securityHeaders(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'self'; style-src 'self'; "+
+			"img-src 'self' data:; connect-src 'self'; form-action 'self'; base-uri 'none'; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "same-origin")
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
 func (s *Server) feeds() []string {
 	feeds := make([]string, 0, len(s.Conf.Feeds))
 	for k := range s.Conf.Feeds {
@@ -0,0 +1,89 @@
+// prepend new items received as server-sent events, enabled by data-events attribute of #items
+(function () {
+    'use strict';
+    var main = document.getElementById('items');
+    if (!main || !main.dataset.events || !window.EventSource) {
+        return;
+    }
+
+    // safeURL allows http and https links only, like html/template does for server-rendered rows
+    var safeURL = function (href) {
+        try {
+            var u = new URL(href, window.location.href);
+            if (u.protocol === 'http:' || u.protocol === 'https:') {
+                return u.href;
+            }
+        } catch (err) {
+            // not a url
+        }
+        return '#ZgotmplZ';
+    };
+
+    var link = function (href, child) {
+        var a = document.createElement('a');
+        a.href = safeURL(href);
+        a.target = '_blank';
+        a.rel = 'noopener';
+        a.appendChild(child);
+        return a;
+    };
+
+    var span = function (cls, text) {
+        var s = document.createElement('span');
+        if (cls) {
+            s.className = cls;
+        }
+        s.textContent = text;
+        return s;
+    };
+
+    var events = new EventSource(main.dataset.events);
+    events.addEventListener('item', function (e) {
+        var item = JSON.parse(e.data);
+        var row = document.createElement('div');
+        row.className = 'ump-feed-master__data-row live-row';
+
+        var player = document.createElement('div');
+        player.className = 'ump-feed-master__data-row-player-cell';
+        if (item.audio) {
+            var icon = document.createElement('img');
+            icon.className = 'icon icon-audio';
+            icon.src = main.dataset.audioIcon;
+            icon.alt = 'audio';
+            icon.title = item.duration || '';
+            player.appendChild(link(item.audio, icon));
+        }
+
+        var info = document.createElement('div');
+        info.className = 'ump-feed-master__data-row-info-cell';
+        var title = document.createElement('div');
+        title.appendChild(link(item.link, span('ump-feed-master-program-name', item.title)));
+        var ts = document.createElement('div');
+        ts.className = 'ump-feed-master-timestamp-cell';
+        if (main.dataset.events === '/events') {
+            var feedLink = document.createElement('a');
+            feedLink.href = '/feed/' + encodeURIComponent(item.feed);
+            feedLink.className = 'ump-feed-master-feed-name';
+            feedLink.textContent = item.feed;
+            ts.appendChild(feedLink);
+        }
+        if (main.dataset.scoring) {
+            ts.appendChild(span('ump-feed-master-score', item.score.toFixed(1)));
+        }
+        (item.tags || []).forEach(function (tag) {
+            var tagLink = document.createElement('a');
+            tagLink.href = '/feed/' + encodeURIComponent(item.feed) + '?tag=' + encodeURIComponent(tag);
+            tagLink.className = 'ump-feed-master-tag';
+            tagLink.textContent = '#' + tag;
+            ts.appendChild(tagLink);
+        });
+        ts.appendChild(span('ump-feed-master-duration-cell', item.duration || ''));
+        ts.appendChild(span('', ' ' + item.dt));
+        info.appendChild(title);
+        info.appendChild(ts);
+
+        row.appendChild(player);
+        row.appendChild(info);
+        main.insertBefore(row, main.firstChild);
+    });
+})();
@@ -3,19 +3,29 @@ package api
 import (
 	"bytes"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
+	log "github.com/go-pkgz/lgr"
 	"github.com/go-pkgz/rest"
 	"github.com/pkg/errors"
 
 	"github.com/umputun/feed-master/app/config"
 	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/search"
 )
 
-// GET /feed/{name} - renders page with list of items
+const defaultPageSize = 50
+
+// GET /feed/{name}?page=2&hide_junk=1&source=name&tag=name&from=2006-01-02&to=2006-01-02&q=text - renders page with list of items.
+// Name can be "_all" for all feeds or combination like "go+rust", junk is always hidden for such merged feeds.
+// Both dates are inclusive. Each combination of parameters is cached separately, the cache is purged on each saved item.
 func (s *Server) getFeedPageCtrl(w http.ResponseWriter, r *http.Request) {
 	if s.cache == nil {
 		s.renderErrorPage(w, r, errors.New("cache not initialized"), 500)
@@ -23,12 +33,25 @@ func (s *Server) getFeedPageCtrl(w http.ResponseWriter, r *http.Request) {
 	}
 
 	feedName := chi.URLParam(r, "name")
+	fs, err := s.feedSet(feedName)
+	if err != nil {
+		s.renderErrorPage(w, r, err, 400)
+		return
+	}
+	fq, err := s.feedPageQuery(r)
+	if err != nil {
+		s.renderErrorPage(w, r, err, 400)
+		return
+	}
 
-	data, err := s.cache.Get(feedName, func() ([]byte, error) {
-		items, err := s.Store.Load(feedName, s.Conf.System.MaxTotal, false)
+	// outbox counts change without new items, so they are a part of the cache key
+	outbox, pending, dead := s.outboxCounts(fs.names...)
+	data, err := s.cache.Get(feedName+"?"+fq.params.Encode()+"#"+outbox, func() ([]byte, error) {
+		items, more, err := s.loadPage(fs, fq.Query)
 		if err != nil {
 			return nil, err
 		}
+		feedConf := s.feedSetConf(fs)
 
 		// fill formatted duration
 		for i, item := range items { //nolint
@@ -42,8 +65,19 @@ func (s *Server) getFeedPageCtrl(w http.ResponseWriter, r *http.Request) {
 			items[i].DurationFmt = d.String()
 		}
 
+		sources := make([]string, 0, len(feedConf.Sources))
+		for _, src := range feedConf.Sources {
+			sources = append(sources, src.Name)
+		}
+		tags := make([]string, 0, len(feedConf.Tags))
+		for t := range feedConf.Tags {
+			tags = append(tags, t)
+		}
+		sort.Strings(tags)
+
 		tmplData := struct {
 			LastUpdate      time.Time
+			FeedName        string
 			Name            string
 			Description     string
 			Link            string
@@ -52,20 +86,57 @@ func (s *Server) getFeedPageCtrl(w http.ResponseWriter, r *http.Request) {
 			RSSLink         string
 			SourcesLink     string
 			TelegramGroupID string
+			PrevLink        string
+			NextLink        string
+			EventsLink      string
+			Filter          url.Values
 			Items           []feed.Item
+			Sources         []string
+			Tags            []string
 			Feeds           int
+			Page            int
+			Merged          bool
+			Scoring         bool
+			Pending         int
+			Dead            int
 		}{
 			Items:           items,
-			Name:            s.Conf.Feeds[feedName].Title,
-			Description:     s.Conf.Feeds[feedName].Description,
-			Link:            s.Conf.Feeds[feedName].Link,
-			LastUpdate:      items[0].DT.In(time.UTC),
-			SinceLastUpdate: humanize.Time(items[0].DT),
-			Feeds:           len(s.Conf.Feeds[feedName].Sources),
+			FeedName:        feedName,
+			Name:            feedConf.Title,
+			Description:     feedConf.Description,
+			Link:            feedConf.Link,
+			Feeds:           len(feedConf.Sources),
 			Version:         s.Version,
 			RSSLink:         s.Conf.System.BaseURL + "/rss/" + feedName,
 			SourcesLink:     s.Conf.System.BaseURL + "/feed/" + feedName + "/sources",
-			TelegramGroupID: s.Conf.Feeds[feedName].TelegramGroupID,
+			TelegramGroupID: feedConf.TelegramGroupID,
+			Filter:          fq.params,
+			Sources:         sources,
+			Tags:            tags,
+			Page:            fq.page,
+			Merged:          fs.merged(),
+			Scoring:         feedConf.Scoring.Enabled,
+			Pending:         pending,
+			Dead:            dead,
+		}
+		// live updates only for the first unfiltered page, a combination of feeds can't be subscribed to
+		if fq.page == 1 && len(fq.params) == 0 {
+			switch {
+			case !fs.merged():
+				tmplData.EventsLink = "/events/" + feedName
+			case fs.name == allFeeds:
+				tmplData.EventsLink = "/events"
+			}
+		}
+		if len(items) > 0 {
+			tmplData.LastUpdate = items[0].DT.In(time.UTC)
+			tmplData.SinceLastUpdate = humanize.Time(items[0].DT)
+		}
+		if fq.page > 1 {
+			tmplData.PrevLink = "/feed/" + feedName + "?" + fq.pageParams(fq.page-1).Encode()
+		}
+		if more {
+			tmplData.NextLink = "/feed/" + feedName + "?" + fq.pageParams(fq.page+1).Encode()
 		}
 
 		res := bytes.NewBuffer(nil)
@@ -82,6 +153,77 @@ func (s *Server) getFeedPageCtrl(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(data)
 }
 
+// feedPageQuery is a parsed query of the feed page
+type feedPageQuery struct {
+	feed.Query
+	params url.Values // normalized non-empty parameters, used for cache key and links
+	page   int
+}
+
+// pageParams returns params for the given page, page 1 is the default and omitted
+func (fq feedPageQuery) pageParams(page int) url.Values {
+	res := url.Values{}
+	for k, v := range fq.params {
+		res[k] = v
+	}
+	res.Del("page")
+	if page > 1 {
+		res.Set("page", strconv.Itoa(page))
+	}
+	return res
+}
+
+// feedPageQuery makes store query from the feed page request parameters
+func (s *Server) feedPageQuery(r *http.Request) (feedPageQuery, error) {
+	params := r.URL.Query()
+	res := feedPageQuery{params: url.Values{}, page: 1}
+	res.Limit = s.Conf.System.MaxTotal
+	if res.Limit <= 0 {
+		res.Limit = defaultPageSize
+	}
+
+	var err error
+	if v := params.Get("page"); v != "" {
+		if res.page, err = strconv.Atoi(v); err != nil || res.page < 1 {
+			return res, errors.Errorf("bad page %q", v)
+		}
+		if res.page > 1 {
+			res.params.Set("page", v)
+		}
+	}
+	res.Offset = (res.page - 1) * res.Limit
+
+	if v := params.Get("hide_junk"); v != "" && v != "0" {
+		res.SkipJunk = true
+		res.params.Set("hide_junk", "1")
+	}
+	if v := params.Get("source"); v != "" {
+		res.Source = v
+		res.params.Set("source", v)
+	}
+	if v := params.Get("tag"); v != "" {
+		res.Tag = strings.ToLower(v)
+		res.params.Set("tag", res.Tag)
+	}
+	if v := strings.TrimSpace(params.Get("q")); v != "" {
+		res.Text = v
+		res.params.Set("q", v)
+	}
+	if v := params.Get("from"); v != "" {
+		if res.From, err = search.ParseDate(v); err != nil {
+			return res, errors.Wrapf(err, "bad from date %q", v)
+		}
+		res.params.Set("from", v)
+	}
+	if v := params.Get("to"); v != "" {
+		if res.To, err = search.ParseEndDate(v); err != nil {
+			return res, errors.Wrapf(err, "bad to date %q", v)
+		}
+		res.params.Set("to", v)
+	}
+	return res, nil
+}
+
 // GET /feeds - renders page with list of feeds
 func (s *Server) getFeedsPageCtrl(w http.ResponseWriter, r *http.Request) {
 	if s.cache == nil {
@@ -89,7 +231,8 @@ func (s *Server) getFeedsPageCtrl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := s.cache.Get("feeds", func() ([]byte, error) {
+	outbox, pending, dead := s.outboxCounts()
+	data, err := s.cache.Get("feeds#"+outbox, func() ([]byte, error) {
 		feeds := s.feeds()
 
 		type feedItem struct {
@@ -119,9 +262,13 @@ func (s *Server) getFeedsPageCtrl(w http.ResponseWriter, r *http.Request) {
 		tmplData := struct {
 			Feeds      []feedItem
 			FeedsCount int
+			Pending    int
+			Dead       int
 		}{
 			Feeds:      feedItems,
 			FeedsCount: len(feedItems),
+			Pending:    pending,
+			Dead:       dead,
 		}
 
 		res := bytes.NewBuffer(nil)
@@ -138,6 +285,59 @@ func (s *Server) getFeedsPageCtrl(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(data)
 }
 
+// GET /search?q=text - renders page with search results, not cached
+func (s *Server) getSearchPageCtrl(w http.ResponseWriter, r *http.Request) {
+	q, err := s.searchQuery(r)
+	if err != nil {
+		s.renderErrorPage(w, r, err, 400)
+		return
+	}
+
+	results, err := s.Store.Search(q)
+	if err != nil {
+		s.renderErrorPage(w, r, err, 500)
+		return
+	}
+
+	tmplData := struct {
+		Query   string
+		Results []search.Result
+		Version string
+	}{
+		Query:   r.URL.Query().Get("q"),
+		Results: results,
+		Version: s.Version,
+	}
+
+	res := bytes.NewBuffer(nil)
+	if err = s.templates.ExecuteTemplate(res, "search.tmpl", &tmplData); err != nil {
+		s.renderErrorPage(w, r, err, 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(res.Bytes())
+}
+
+// outboxCounts returns pending and dead telegram deliveries of the feeds, all feeds if none given.
+// The first value is a short form of counts, used in cache keys.
+func (s *Server) outboxCounts(feeds ...string) (key string, pending, dead int) {
+	st, err := s.Store.OutboxStats()
+	if err != nil {
+		log.Printf("[WARN] can't get outbox stats, %v", err)
+		return "", 0, 0
+	}
+	if len(feeds) == 0 {
+		pending, dead = st.Pending, st.Dead
+	}
+	for _, f := range feeds {
+		pending += st.Feeds[f]
+		dead += st.Failed[f]
+	}
+	return strconv.Itoa(pending) + "/" + strconv.Itoa(dead), pending, dead
+}
+
 func (s *Server) renderErrorPage(w http.ResponseWriter, r *http.Request, err error, errCode int) {
 	tmplData := struct {
 		Error  string
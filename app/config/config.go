@@ -3,12 +3,13 @@ package config
 
 import (
 	"os"
-	"regexp"
+	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 
-	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/message"
 )
 
 // Conf for feeds config yml
@@ -22,53 +23,88 @@ type Conf struct {
 		MaxTotal            int           `yaml:"max_total"`
 		MaxKeepInDB         int           `yaml:"max_keep"`
 		Concurrent          int           `yaml:"concurrent"`
+		MaxAge              time.Duration `yaml:"max_age"`
+		TelegramRate        struct {
+			Global  int `yaml:"global"`   // messages per second to all chats
+			PerChat int `yaml:"per_chat"` // messages per minute to each chat
+		} `yaml:"telegram_rate"`
 	} `yaml:"system"`
 }
 
 // Source defines config section for source
 type Source struct {
-	Name string `yaml:"name"`
-	URL  string `yaml:"url"`
+	Name            string `yaml:"name"`
+	URL             string `yaml:"url"`
+	Filter          Filter `yaml:"filter"`
+	InitialPosts    int    `yaml:"initial_posts"`     // newest items to send on the first fetch, feed's value if zero
+	TelegramTopicID int    `yaml:"telegram_topic_id"` // forum topic in the feed's telegram group, feed's one if zero
+	Limits          `yaml:",inline"`
 }
 
 // Feed defines config section for a feed~
 type Feed struct {
-	Title           string   `yaml:"title"`
-	Description     string   `yaml:"description"`
-	Link            string   `yaml:"link"`
-	Image           string   `yaml:"image"`
-	Language        string   `yaml:"language"`
-	TelegramGroupID string   `yaml:"telegram_group_id"`
-	ExtendDateTitle string   `yaml:"ext_date"`
-	Author          string   `yaml:"author"`
-	OwnerEmail      string   `yaml:"owner_email"`
-	Filter          Filter   `yaml:"filter"`
-	Sources         []Source `yaml:"sources"`
+	Title                string        `yaml:"title"`
+	Description          string        `yaml:"description"`
+	Link                 string        `yaml:"link"`
+	Image                string        `yaml:"image"`
+	Language             string        `yaml:"language"`
+	Languages            []string      `yaml:"languages"` // detected languages allowed, other items are junk
+	TelegramGroupID      string        `yaml:"telegram_group_id"`
+	TelegramTopicID      int           `yaml:"telegram_topic_id"`     // forum topic in the telegram group
+	TelegramDestinations []Destination `yaml:"telegram_destinations"` // more chats to send to
+	TelegramMessage      string        `yaml:"telegram_message"`      // text/template of telegram message, see message package
+	TelegramPost         Post          `yaml:"telegram_post"`
+	Updates              Updates       `yaml:"updates"` // edits and removals of sent posts
+	ExtendDateTitle      string        `yaml:"ext_date"`
+	Author               string        `yaml:"author"`
+	OwnerEmail           string        `yaml:"owner_email"`
+	Filter               Filter        `yaml:"filter"`
+	Tags                 Tags          `yaml:"tags"`
+	Dedup                Dedup         `yaml:"dedup"`
+	NearDup              NearDup       `yaml:"near_dup"`
+	Scoring              Scoring       `yaml:"scoring"`
+	Delivery             Delivery      `yaml:"delivery"`
+	Sources              []Source      `yaml:"sources"`
+	InitialPosts         int           `yaml:"initial_posts"` // newest items to send on the first fetch of a source
+	Limits               `yaml:",inline"`
+
+	message *message.Template
 }
 
-// Filter defines feed section for a feed filter~
-type Filter struct {
-	Title  string `yaml:"title"`
-	Invert bool   `yaml:"invert"`
+// Dedup defines deduplication of items from different sources of the feed by canonical link
+type Dedup struct {
+	Enabled bool          `yaml:"enabled"`
+	Window  time.Duration `yaml:"window"` // how far back to look for the original, 72h by default
 }
 
-// Skip items with this regexp
-func (filter *Filter) Skip(item feed.Item) (bool, error) {
-	mayInvert := func(b bool) bool {
-		if filter.Invert {
-			return !b
-		}
-		return b
+// NearDup defines detection of items with nearly the same title and description, by SimHash signatures
+type NearDup struct {
+	Enabled  bool          `yaml:"enabled"`
+	Distance *int          `yaml:"distance"` // max Hamming distance of signatures, 3 by default, 0 for exact match
+	Window   time.Duration `yaml:"window"`   // how far back to look for the original, 48h by default
+}
+
+// Message returns telegram message template of the feed
+func (f Feed) Message() *message.Template {
+	if f.message == nil {
+		return defaultMessage
 	}
+	return f.message
+}
+
+var defaultMessage = message.MustCompile(message.Default)
 
-	if filter.Title != "" {
-		matched, err := regexp.MatchString(filter.Title, item.Title)
-		if err != nil {
-			return mayInvert(matched), err
+// LanguageAllowed checks if items in the detected language pass the feed, unknown language always passes
+func (f Feed) LanguageAllowed(code string) bool {
+	if code == "" || len(f.Languages) == 0 {
+		return true
+	}
+	for _, l := range f.Languages {
+		if l == code {
+			return true
 		}
-		return mayInvert(matched), nil
 	}
-	return false, nil
+	return false
 }
 
 // Load config from file
@@ -83,8 +119,118 @@ func Load(fname string) (res *Conf, err error) {
 		return nil, err
 	}
 	res.setDefaults()
+	if err := res.validate(); err != nil {
+		return nil, err
+	}
+	if err := res.compile(); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
 
+// checkFeedName rejects names reserved by web ui and store. Names prefixed with "_" are system buckets
+// and pseudo-feeds like "_all", "+" joins names of combined feeds.
+func checkFeedName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return errors.New("empty feed name")
+	case strings.HasPrefix(name, "_"):
+		return errors.Errorf("feed %s: name can't start with \"_\", reserved for system use", name)
+	case strings.Contains(name, "+"):
+		return errors.Errorf("feed %s: name can't contain \"+\", used to combine feeds", name)
+	}
+	return nil
+}
+
+// compile precompiles filters, tags and message templates of all feeds and sources
+func (c *Conf) compile() error {
+	for name, fc := range c.Feeds {
+		if err := fc.Filter.Compile(); err != nil {
+			return errors.Wrapf(err, "feed %s", name)
+		}
+		if err := fc.Tags.compile(); err != nil {
+			return errors.Wrapf(err, "feed %s", name)
+		}
+		var err error
+		if fc.message, err = message.Compile(fc.TelegramMessage); err != nil {
+			return errors.Wrapf(err, "feed %s, bad telegram_message", name)
+		}
+		if err = fc.TelegramPost.validate(); err != nil {
+			return errors.Wrapf(err, "feed %s, telegram_post", name)
+		}
+		if err = fc.Updates.validate(); err != nil {
+			return errors.Wrapf(err, "feed %s, updates", name)
+		}
+		if err = fc.Delivery.compile(); err != nil {
+			return errors.Wrapf(err, "feed %s, delivery", name)
+		}
+		for i, dst := range fc.TelegramDestinations {
+			if dst.Chat == "" {
+				return errors.Errorf("feed %s, telegram destination %d without chat", name, i+1)
+			}
+		}
+		for i := range fc.Sources {
+			if err := fc.Sources[i].Filter.Compile(); err != nil {
+				return errors.Wrapf(err, "feed %s, source %s", name, fc.Sources[i].Name)
+			}
+		}
+		c.Feeds[name] = fc
+	}
+	return nil
+}
+
 // SetDefaults sets default values for config
-func (c *Conf) setDefaults() {}
+func (c *Conf) setDefaults() {
+	if c.System.UpdateInterval == 0 {
+		c.System.UpdateInterval = defaultUpdateInterval
+	}
+	if c.System.HTTPResponseTimeout == 0 {
+		c.System.HTTPResponseTimeout = defaultHTTPResponseTimeout
+	}
+	if c.System.MaxItems == 0 {
+		c.System.MaxItems = defaultMaxItems
+	}
+	if c.System.MaxTotal == 0 {
+		c.System.MaxTotal = defaultMaxTotal
+	}
+	if c.System.MaxKeepInDB == 0 {
+		c.System.MaxKeepInDB = defaultMaxKeepInDB
+	}
+	if c.System.Concurrent == 0 {
+		c.System.Concurrent = defaultConcurrent
+	}
+	if c.System.MaxAge == 0 {
+		c.System.MaxAge = defaultMaxAge
+	}
+	if c.System.TelegramRate.Global == 0 {
+		c.System.TelegramRate.Global = defaultTelegramGlobalRate
+	}
+	if c.System.TelegramRate.PerChat == 0 {
+		c.System.TelegramRate.PerChat = defaultTelegramChatRate
+	}
+	for name, fc := range c.Feeds {
+		if fc.Dedup.Window == 0 {
+			fc.Dedup.Window = 72 * time.Hour
+		}
+		if fc.NearDup.Distance == nil {
+			distance := 3
+			fc.NearDup.Distance = &distance
+		}
+		if fc.NearDup.Window == 0 {
+			fc.NearDup.Window = 48 * time.Hour
+		}
+		if fc.Scoring.RecencyWindow == 0 {
+			fc.Scoring.RecencyWindow = 24 * time.Hour
+		}
+		if fc.Delivery.MaxItems == 0 {
+			fc.Delivery.MaxItems = 20
+		}
+		if fc.Updates.Grace == 0 {
+			fc.Updates.Grace = 24 * time.Hour
+		}
+		if fc.Delivery.Release == 0 {
+			fc.Delivery.Release = time.Minute
+		}
+		c.Feeds[name] = fc
+	}
+}
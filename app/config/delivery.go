@@ -0,0 +1,106 @@
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/cron"
+)
+
+// Delivery defines how items are sent to telegram, each one immediately (default) or in periodic digests,
+// and when, any time or within windows only
+type Delivery struct {
+	Mode     string        `yaml:"mode"`      // immediate or digest
+	Schedule string        `yaml:"schedule"`  // cron schedule of digests, like "0 */3 * * *"
+	MaxItems int           `yaml:"max_items"` // items in a digest, best by score if scoring enabled, newest otherwise
+	Windows  []Window      `yaml:"windows"`   // any time if empty
+	Timezone string        `yaml:"timezone"`  // of windows and schedule, like Europe/Kyiv, local by default
+	OffHours string        `yaml:"off_hours"` // hold messages until window opens (default) or send them silently
+	Release  time.Duration `yaml:"release"`   // interval between held messages when window opens
+
+	schedule *cron.Schedule
+	loc      *time.Location
+}
+
+// delivery modes
+const (
+	DeliveryImmediate = "immediate"
+	DeliveryDigest    = "digest"
+)
+
+// Digest checks if items are sent in digests
+func (d Delivery) Digest() bool {
+	return d.Mode == DeliveryDigest
+}
+
+// NextDigest returns time of the next digest after t, zero if the schedule never matches
+func (d Delivery) NextDigest(t time.Time) time.Time {
+	if d.schedule == nil {
+		return time.Time{}
+	}
+	return d.schedule.Next(t.In(d.location()))
+}
+
+// WindowStart returns start of the delivery window open at t, zero if all windows are closed.
+// Without windows delivery is always open, since zero time.
+func (d Delivery) WindowStart(t time.Time) (start time.Time, open bool) {
+	if len(d.Windows) == 0 {
+		return time.Time{}, true
+	}
+	t = t.In(d.location())
+	for _, w := range d.Windows {
+		if s := w.start(t); !s.IsZero() && (!open || s.Before(start)) {
+			start, open = s, true
+		}
+	}
+	return start, open
+}
+
+// Silent checks if messages out of windows are sent without notification instead of being held
+func (d Delivery) Silent() bool {
+	return d.OffHours == OffHoursSilent
+}
+
+func (d Delivery) location() *time.Location {
+	if d.loc == nil {
+		return time.Local
+	}
+	return d.loc
+}
+
+func (d *Delivery) compile() (err error) {
+	if d.Timezone != "" {
+		if d.loc, err = time.LoadLocation(d.Timezone); err != nil {
+			return errors.Wrapf(err, "bad timezone %q", d.Timezone)
+		}
+	}
+	switch d.OffHours {
+	case "", OffHoursHold, OffHoursSilent:
+	default:
+		return errors.Errorf("unknown off_hours %q", d.OffHours)
+	}
+	if d.Release < 0 {
+		return errors.Errorf("negative release %s", d.Release)
+	}
+	for i := range d.Windows {
+		if err = d.Windows[i].compile(); err != nil {
+			return errors.Wrapf(err, "window %d", i+1)
+		}
+	}
+
+	switch d.Mode {
+	case "", DeliveryImmediate:
+		return nil
+	case DeliveryDigest:
+	default:
+		return errors.Errorf("unknown mode %q", d.Mode)
+	}
+	if d.MaxItems < 0 {
+		return errors.Errorf("negative max_items %d", d.MaxItems)
+	}
+	if d.schedule, err = cron.Parse(d.Schedule); err != nil {
+		return errors.Wrap(err, "bad schedule")
+	}
+	return nil
+}
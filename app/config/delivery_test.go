@@ -0,0 +1,107 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowCompile(t *testing.T) {
+	tbl := []struct {
+		name    string
+		w       Window
+		wantErr bool
+	}{
+		{name: "every day", w: Window{From: "08:00", To: "20:00"}},
+		{name: "days and ranges", w: Window{Days: []string{"Mon-Wed", " fri ", "sat-sun"}, From: "08:00", To: "24:00"}},
+		{name: "wrapping range", w: Window{Days: []string{"fri-mon"}, From: "22:00", To: "02:00"}},
+		{name: "bad day", w: Window{Days: []string{"monday"}, From: "08:00", To: "20:00"}, wantErr: true},
+		{name: "bad range", w: Window{Days: []string{"mon-xyz"}, From: "08:00", To: "20:00"}, wantErr: true},
+		{name: "bad from", w: Window{From: "8am", To: "20:00"}, wantErr: true},
+		{name: "bad to", w: Window{From: "08:00", To: "25:00"}, wantErr: true},
+		{name: "empty", w: Window{}, wantErr: true},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.w.compile()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	w := Window{Days: []string{"fri-mon"}, From: "08:00", To: "20:00"}
+	if err := w.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if want := [7]bool{true, true, false, false, false, true, true}; w.days != want {
+		t.Errorf("days %v, want %v", w.days, want)
+	}
+}
+
+func TestWindowStart(t *testing.T) {
+	at := func(s string) time.Time { // 2024-05-06 is monday
+		res, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	tbl := []struct {
+		name string
+		w    Window
+		t    time.Time
+		want time.Time
+	}{
+		{"inside", Window{From: "08:00", To: "20:00"}, at("2024-05-06 12:00"), at("2024-05-06 08:00")},
+		{"at from", Window{From: "08:00", To: "20:00"}, at("2024-05-06 08:00"), at("2024-05-06 08:00")},
+		{"at to", Window{From: "08:00", To: "20:00"}, at("2024-05-06 20:00"), time.Time{}},
+		{"before", Window{From: "08:00", To: "20:00"}, at("2024-05-06 07:59"), time.Time{}},
+		{"till midnight", Window{From: "08:00", To: "24:00"}, at("2024-05-06 23:59"), at("2024-05-06 08:00")},
+		{"overnight evening", Window{From: "22:00", To: "02:00"}, at("2024-05-06 23:00"), at("2024-05-06 22:00")},
+		{"overnight morning", Window{From: "22:00", To: "02:00"}, at("2024-05-07 01:00"), at("2024-05-06 22:00")},
+		{"overnight closed", Window{From: "22:00", To: "02:00"}, at("2024-05-07 03:00"), time.Time{}},
+		{"whole day", Window{From: "00:00", To: "00:00"}, at("2024-05-06 15:00"), at("2024-05-06 00:00")},
+		{"weekday", Window{Days: []string{"mon-fri"}, From: "08:00", To: "20:00"}, at("2024-05-10 12:00"), at("2024-05-10 08:00")},
+		{"weekend", Window{Days: []string{"mon-fri"}, From: "08:00", To: "20:00"}, at("2024-05-11 12:00"), time.Time{}},
+		{"overnight past day", Window{Days: []string{"fri"}, From: "22:00", To: "02:00"}, at("2024-05-11 01:00"), at("2024-05-10 22:00")},
+		{"overnight not started", Window{Days: []string{"fri"}, From: "22:00", To: "02:00"}, at("2024-05-10 01:00"), time.Time{}},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.w.compile(); err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.w.start(tt.t); !got.Equal(tt.want) {
+				t.Errorf("start(%s) = %s, want %s", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryWindowStart(t *testing.T) {
+	d := Delivery{Timezone: "Europe/Kyiv", Windows: []Window{{From: "08:00", To: "12:00"}, {From: "10:00", To: "20:00"}}}
+	if err := d.compile(); err != nil {
+		t.Skip("no tz data", err)
+	}
+	tbl := []struct {
+		t     time.Time
+		start time.Time
+		open  bool
+	}{
+		{time.Date(2024, 5, 6, 4, 0, 0, 0, time.UTC), time.Time{}, false},                                 // 07:00 local
+		{time.Date(2024, 5, 6, 5, 0, 0, 0, time.UTC), time.Date(2024, 5, 6, 5, 0, 0, 0, time.UTC), true},  // 08:00
+		{time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC), time.Date(2024, 5, 6, 5, 0, 0, 0, time.UTC), true},  // 11:00, earliest
+		{time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC), time.Date(2024, 5, 6, 7, 0, 0, 0, time.UTC), true}, // 13:00
+		{time.Date(2024, 5, 6, 17, 0, 0, 0, time.UTC), time.Time{}, false},                                // 20:00
+	}
+	for _, tt := range tbl {
+		start, open := d.WindowStart(tt.t)
+		if open != tt.open || !start.Equal(tt.start) {
+			t.Errorf("WindowStart(%s) = %s, %v, want %s, %v", tt.t, start, open, tt.start, tt.open)
+		}
+	}
+
+	if start, open := (Delivery{}).WindowStart(time.Now()); !open || !start.IsZero() {
+		t.Errorf("no windows got %s, %v, want always open", start, open)
+	}
+}
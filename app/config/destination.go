@@ -0,0 +1,23 @@
+package config
+
+// Destination is a telegram chat to send items of the feed to, with optional forum topic
+type Destination struct {
+	Chat  string `yaml:"chat"`     // chat id or @name, like telegram_group_id
+	Topic int    `yaml:"topic_id"` // message thread id, general topic if zero
+}
+
+// Destinations returns chats to send items of the source to, the feed's telegram group in topic of the source
+// or the feed, followed by other destinations. Empty if the feed isn't sent to telegram.
+func (f Feed) Destinations(source string) []Destination {
+	var res []Destination
+	if f.TelegramGroupID != "" {
+		dst := Destination{Chat: f.TelegramGroupID, Topic: f.TelegramTopicID}
+		for _, src := range f.Sources {
+			if src.Name == source && src.TelegramTopicID != 0 {
+				dst.Topic = src.TelegramTopicID
+			}
+		}
+		res = append(res, dst)
+	}
+	return append(res, f.TelegramDestinations...)
+}
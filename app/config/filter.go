@@ -0,0 +1,254 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/expr"
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// Filter defines feed or source section for a filter, marking matched items as junk.
+// Title and Invert are the legacy single regex filter, Rules and Expr are checked in addition to it.
+type Filter struct {
+	Title  string      `yaml:"title"`
+	Invert bool        `yaml:"invert"`
+	Rules  []Rule      `yaml:"rules"`
+	Arxiv  ArxivFilter `yaml:"arxiv"`
+	Expr   string      `yaml:"expr"` // item is junk if the expression is true, see expr package for the syntax
+
+	titleRe *regexp.Regexp
+	expr    *expr.Program
+}
+
+// ArxivFilter marks arXiv items as junk by announce type, primary category and authors, other items pass
+type ArxivFilter struct {
+	Skip    []string `yaml:"skip"`    // announce types to skip, like replace and replace-cross
+	Primary []string `yaml:"primary"` // junk unless primary category is one of
+	Authors []string `yaml:"authors"` // junk unless one of authors is in the list, case-insensitive
+}
+
+// Rule marks item as junk if matched (exclude, default) or if no include rule matched (include)
+type Rule struct {
+	Name   string `yaml:"name"`
+	Action string `yaml:"action"` // exclude or include
+	Match  `yaml:",inline"`
+}
+
+// Match is a condition of the rule. Leaf condition matches if any of keywords (case-insensitive)
+// or regex found in any of fields. Any and All are groups of nested conditions joined with OR and AND.
+// All parts set in the same Match must be true.
+type Match struct {
+	Fields   []string `yaml:"fields"` // title (default), description, content, author, domain, category, tag
+	Keywords []string `yaml:"keywords"`
+	Regex    string   `yaml:"regex"`
+	Any      []Match  `yaml:"any"`
+	All      []Match  `yaml:"all"`
+
+	re       *regexp.Regexp
+	keywords []string
+}
+
+// rule actions
+const (
+	actionExclude = "exclude"
+	actionInclude = "include"
+)
+
+var matchFields = map[string]func(feed.Item) []string{
+	"title":       func(item feed.Item) []string { return []string{item.Title} },
+	"description": func(item feed.Item) []string { return []string{string(item.Description)} },
+	"content":     func(item feed.Item) []string { return []string{string(item.Content)} },
+	"author":      func(item feed.Item) []string { return []string{item.Author} },
+	"domain":      func(item feed.Item) []string { return []string{linkDomain(item.Link)} },
+	"category":    func(item feed.Item) []string { return item.Categories },
+	"tag":         func(item feed.Item) []string { return item.Tags },
+}
+
+// Compile validates and precompiles title regex and all rules
+func (filter *Filter) Compile() (err error) {
+	if filter.Title != "" {
+		if filter.titleRe, err = regexp.Compile(filter.Title); err != nil {
+			return errors.Wrapf(err, "bad title filter %q", filter.Title)
+		}
+	}
+	if filter.Expr != "" {
+		if filter.expr, err = expr.Compile(filter.Expr); err != nil {
+			return errors.Wrapf(err, "bad expr %q", filter.Expr)
+		}
+	}
+	for i := range filter.Rules {
+		r := &filter.Rules[i]
+		if r.Name == "" {
+			r.Name = "#" + strconv.Itoa(i+1)
+		}
+		switch r.Action {
+		case "":
+			r.Action = actionExclude
+		case actionExclude, actionInclude:
+		default:
+			return errors.Errorf("rule %s: unknown action %q", r.Name, r.Action)
+		}
+		if err = r.Match.compile(); err != nil {
+			return errors.Wrapf(err, "rule %s", r.Name)
+		}
+	}
+	return nil
+}
+
+// JunkReason returns the reason to mark env's item as junk, empty if the item passes the filter
+func (filter *Filter) JunkReason(env expr.Env) string {
+	item := env.Item
+	if filter.Title != "" {
+		var matched bool
+		if filter.titleRe != nil {
+			matched = filter.titleRe.MatchString(item.Title)
+		} else {
+			matched, _ = regexp.MatchString(filter.Title, item.Title) // not compiled, bad regex never matches
+		}
+		if matched != filter.Invert {
+			return "title filter"
+		}
+	}
+
+	hasInclude, included := false, false
+	for i := range filter.Rules {
+		r := &filter.Rules[i]
+		if r.Action == actionInclude {
+			hasInclude = true
+			included = included || r.Match.match(item)
+			continue
+		}
+		if r.Match.match(item) {
+			return "rule " + r.Name
+		}
+	}
+	if hasInclude && !included {
+		return "no include rule matched"
+	}
+
+	if reason := filter.Arxiv.junkReason(item.Arxiv); reason != "" {
+		return reason
+	}
+
+	if filter.expr != nil && filter.expr.Match(env) {
+		return "expr"
+	}
+	return ""
+}
+
+func (af *ArxivFilter) junkReason(a *feed.Arxiv) string {
+	if a == nil {
+		return ""
+	}
+	if containsFold(af.Skip, a.Announce) {
+		return "arxiv " + a.Announce
+	}
+	if len(af.Primary) > 0 && !containsFold(af.Primary, a.Primary) {
+		return "arxiv primary " + a.Primary
+	}
+	if len(af.Authors) == 0 {
+		return ""
+	}
+	for _, author := range a.Authors {
+		if containsFold(af.Authors, author) {
+			return ""
+		}
+	}
+	return "arxiv authors"
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Match) compile() (err error) {
+	if len(m.Fields) == 0 {
+		m.Fields = []string{"title"}
+	}
+	for _, f := range m.Fields {
+		if _, ok := matchFields[f]; !ok {
+			return errors.Errorf("unknown field %q", f)
+		}
+	}
+	if m.Regex != "" {
+		if m.re, err = regexp.Compile(m.Regex); err != nil {
+			return errors.Wrapf(err, "bad regex %q", m.Regex)
+		}
+	}
+	m.keywords = make([]string, 0, len(m.Keywords))
+	for _, k := range m.Keywords {
+		m.keywords = append(m.keywords, strings.ToLower(k))
+	}
+	if len(m.Keywords) == 0 && m.Regex == "" && len(m.Any) == 0 && len(m.All) == 0 {
+		return errors.New("empty condition")
+	}
+	for i := range m.Any {
+		if err = m.Any[i].compile(); err != nil {
+			return err
+		}
+	}
+	for i := range m.All {
+		if err = m.All[i].compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Match) match(item feed.Item) bool {
+	if len(m.keywords) > 0 || m.re != nil {
+		if !m.matchLeaf(item) {
+			return false
+		}
+	}
+	for i := range m.All {
+		if !m.All[i].match(item) {
+			return false
+		}
+	}
+	if len(m.Any) == 0 {
+		return true
+	}
+	for i := range m.Any {
+		if m.Any[i].match(item) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Match) matchLeaf(item feed.Item) bool {
+	for _, f := range m.Fields {
+		for _, val := range matchFields[f](item) {
+			if m.re != nil && m.re.MatchString(val) {
+				return true
+			}
+			lval := strings.ToLower(val)
+			for _, k := range m.keywords {
+				if strings.Contains(lval, k) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// linkDomain returns host of the link without www. prefix
+func linkDomain(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
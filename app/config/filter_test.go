@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/umputun/feed-master/app/expr"
+	"github.com/umputun/feed-master/app/feed"
+)
+
+func TestFilterCompile(t *testing.T) {
+	tbl := []struct {
+		name   string
+		filter Filter
+		err    string
+	}{
+		{name: "empty", filter: Filter{}},
+		{name: "title and rules", filter: Filter{Title: "^ad:", Expr: "score > 1",
+			Rules: []Rule{{Match: Match{Keywords: []string{"promo"}}}, {Action: "include", Match: Match{Regex: "go"}}}}},
+		{name: "bad title", filter: Filter{Title: "("}, err: `bad title filter "("`},
+		{name: "bad expr", filter: Filter{Expr: "title"}, err: `bad expr "title"`},
+		{name: "bad rule regex", filter: Filter{Rules: []Rule{{Name: "ads", Match: Match{Regex: "[a-"}}}},
+			err: `rule ads: bad regex "[a-"`},
+		{name: "bad nested regex", filter: Filter{Rules: []Rule{{Match: Match{Any: []Match{{Keywords: []string{"a"}},
+			{All: []Match{{Regex: "("}}}}}}}}, err: `rule #1: bad regex "("`},
+		{name: "unknown action", filter: Filter{Rules: []Rule{{Action: "drop", Match: Match{Keywords: []string{"a"}}}}},
+			err: `rule #1: unknown action "drop"`},
+		{name: "unknown field", filter: Filter{Rules: []Rule{{Match: Match{Fields: []string{"body"},
+			Keywords: []string{"a"}}}}}, err: `rule #1: unknown field "body"`},
+		{name: "empty condition", filter: Filter{Rules: []Rule{{}, {}}}, err: "rule #1: empty condition"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Compile()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFilterJunkReason(t *testing.T) {
+	item := feed.Item{Title: "Go 1.23 Released", Description: "<p>Range over func iterators</p>", Content: "full text",
+		Author: "Gopher", Link: "https://www.Go.dev/blog/go1.23", Categories: []string{"release"}, Tags: []string{"golang"}}
+	kw := func(fields ...string) func(...string) Match {
+		return func(keywords ...string) Match { return Match{Fields: fields, Keywords: keywords} }
+	}
+	tbl := []struct {
+		name   string
+		filter Filter
+		item   feed.Item
+		want   string
+	}{
+		{name: "empty", want: ""},
+		{name: "title", filter: Filter{Title: `\d+\.\d+`}, want: "title filter"},
+		{name: "title not matched", filter: Filter{Title: "^Rust"}, want: ""},
+		{name: "title inverted", filter: Filter{Title: "^Rust", Invert: true}, want: "title filter"},
+		{name: "title inverted matched", filter: Filter{Title: "^Go", Invert: true}, want: ""},
+		{name: "keyword case-insensitive", filter: Filter{Rules: []Rule{{Name: "ver", Match: kw()("RELEASED")}}},
+			want: "rule ver"},
+		{name: "keyword in other field", filter: Filter{Rules: []Rule{{Match: kw()("iterators")}}}, want: ""},
+		{name: "description", filter: Filter{Rules: []Rule{{Match: kw("description")("iterators")}}}, want: "rule #1"},
+		{name: "content", filter: Filter{Rules: []Rule{{Match: kw("content")("full")}}}, want: "rule #1"},
+		{name: "author", filter: Filter{Rules: []Rule{{Match: kw("author")("gopher")}}}, want: "rule #1"},
+		{name: "domain", filter: Filter{Rules: []Rule{{Match: Match{Fields: []string{"domain"}, Regex: "^go.dev$"}}}},
+			want: "rule #1"},
+		{name: "category", filter: Filter{Rules: []Rule{{Match: kw("category")("release")}}}, want: "rule #1"},
+		{name: "tag", filter: Filter{Rules: []Rule{{Match: kw("tag")("golang")}}}, want: "rule #1"},
+		{name: "any field", filter: Filter{Rules: []Rule{{Match: kw("title", "author")("gopher")}}}, want: "rule #1"},
+		{name: "regex case-sensitive", filter: Filter{Rules: []Rule{{Match: Match{Regex: "released"}}}}, want: ""},
+		{name: "first matched rule", filter: Filter{Rules: []Rule{{Name: "a", Match: kw()("rust")},
+			{Name: "b", Match: kw()("go")}, {Name: "c", Match: kw()("released")}}}, want: "rule b"},
+		{name: "any group", filter: Filter{Rules: []Rule{{Match: Match{Any: []Match{kw()("rust"), kw("tag")("golang")}}}}},
+			want: "rule #1"},
+		{name: "any group not matched", filter: Filter{Rules: []Rule{{Match: Match{Any: []Match{kw()("rust"),
+			kw("tag")("zig")}}}}}, want: ""},
+		{name: "all group", filter: Filter{Rules: []Rule{{Match: Match{All: []Match{kw()("go"), kw("author")("gopher")}}}}},
+			want: "rule #1"},
+		{name: "all group partial", filter: Filter{Rules: []Rule{{Match: Match{All: []Match{kw()("go"),
+			kw("author")("rob")}}}}}, want: ""},
+		{name: "leaf and group", filter: Filter{Rules: []Rule{{Match: Match{Keywords: []string{"rust"},
+			Any: []Match{kw("tag")("golang")}}}}}, want: ""},
+		{name: "include matched", filter: Filter{Rules: []Rule{{Action: "include", Match: kw()("rust")},
+			{Action: "include", Match: kw()("go")}}}, want: ""},
+		{name: "include not matched", filter: Filter{Rules: []Rule{{Action: "include", Match: kw()("rust")}}},
+			want: "no include rule matched"},
+		{name: "exclude wins over include", filter: Filter{Rules: []Rule{{Action: "include", Match: kw()("go")},
+			{Name: "ver", Match: kw()("1.23")}}}, want: "rule ver"},
+		{name: "title before rules", filter: Filter{Title: "Go", Rules: []Rule{{Match: kw()("go")}}}, want: "title filter"},
+		{name: "arxiv skip", filter: Filter{Arxiv: ArxivFilter{Skip: []string{"Replace"}}},
+			item: feed.Item{Arxiv: &feed.Arxiv{Announce: "replace"}}, want: "arxiv replace"},
+		{name: "arxiv primary", filter: Filter{Arxiv: ArxivFilter{Primary: []string{"cs.CV"}}},
+			item: feed.Item{Arxiv: &feed.Arxiv{Primary: "cs.LG"}}, want: "arxiv primary cs.LG"},
+		{name: "arxiv authors", filter: Filter{Arxiv: ArxivFilter{Authors: []string{"alice smith"}}},
+			item: feed.Item{Arxiv: &feed.Arxiv{Authors: []string{"Bob Jones", "Alice Smith"}}}, want: ""},
+		{name: "arxiv authors not listed", filter: Filter{Arxiv: ArxivFilter{Authors: []string{"alice smith"}}},
+			item: feed.Item{Arxiv: &feed.Arxiv{Authors: []string{"Bob Jones"}}}, want: "arxiv authors"},
+		{name: "arxiv filter passes other items", filter: Filter{Arxiv: ArxivFilter{Primary: []string{"cs.CV"}}}, want: ""},
+		{name: "expr", filter: Filter{Expr: `"golang" in tags`}, want: "expr"},
+		{name: "expr false", filter: Filter{Expr: `"rust" in tags`}, want: ""},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Compile(); err != nil {
+				t.Fatal(err)
+			}
+			it := item
+			if tt.item.Arxiv != nil {
+				it = tt.item
+			}
+			if got := tt.filter.JunkReason(expr.Env{Item: it}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFilterOrder checks filters loaded from config, processor applies the feed filter first and
+// the source filter only to items passed it
+func TestFilterOrder(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "fm.yml")
+	conf := `
+feeds:
+  news:
+    filter:
+      title: "^Ad:"
+      rules:
+        - name: feed-promo
+          keywords: [promo]
+    sources:
+      - name: blog
+        url: https://example.com/rss
+        filter:
+          rules:
+            - name: source-promo
+              keywords: [promo, sale]
+`
+	if err := os.WriteFile(fname, []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm := c.Feeds["news"]
+	src := fm.Sources[0]
+
+	tbl := []struct {
+		title, want string
+	}{
+		{"Ad: promo of the day", "title filter"},
+		{"Big promo", "rule feed-promo"},
+		{"Summer sale", "rule source-promo"},
+		{"Release notes", ""},
+	}
+	for _, tt := range tbl {
+		env := expr.Env{Item: feed.Item{Title: tt.title}, Feed: "news", Source: src.Name}
+		reason := fm.Filter.JunkReason(env)
+		if reason == "" {
+			reason = src.Filter.JunkReason(env)
+		}
+		if reason != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.title, reason, tt.want)
+		}
+	}
+	if fm.Filter.titleRe == nil || src.Filter.Rules[0].Match.keywords == nil {
+		t.Error("filters not compiled on load")
+	}
+}
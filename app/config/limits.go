@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/lang"
+)
+
+// Limits can be set on system, feed and source levels, the most specific non-zero value wins
+type Limits struct {
+	MaxAge      time.Duration `yaml:"max_age"`       // skip items older than this
+	MaxPerFetch int           `yaml:"max_per_fetch"` // process up to this number of items from each fetch
+	MaxKeep     int           `yaml:"max_keep"`      // keep up to this number of items in db, per feed or per source
+	Update      time.Duration `yaml:"update"`        // how often to fetch
+}
+
+// system defaults, applied if not set in config
+const (
+	defaultUpdateInterval      = 5 * time.Minute
+	defaultHTTPResponseTimeout = 30 * time.Second
+	defaultMaxItems            = 5
+	defaultMaxTotal            = 100
+	defaultMaxKeepInDB         = 5000
+	defaultConcurrent          = 8
+	defaultMaxAge              = 365 * 24 * time.Hour
+	defaultTelegramGlobalRate  = 30 // per second
+	defaultTelegramChatRate    = 20 // per minute
+)
+
+// String returns limits in a human-readable form
+func (l Limits) String() string {
+	return fmt.Sprintf("max_age=%s, max_per_fetch=%d, max_keep=%d, update=%s", l.MaxAge, l.MaxPerFetch, l.MaxKeep, l.Update)
+}
+
+// FeedLimits returns effective limits of the feed, feed level over system level
+func (c *Conf) FeedLimits(fmFeed string) Limits {
+	res := Limits{
+		MaxAge:      c.System.MaxAge,
+		MaxPerFetch: c.System.MaxItems,
+		MaxKeep:     c.System.MaxKeepInDB,
+		Update:      c.System.UpdateInterval,
+	}
+	return res.override(c.Feeds[fmFeed].Limits)
+}
+
+// SourceLimits returns effective limits of the feed's source, source level over feed and system levels.
+// MaxKeep is zero if not set for the source, as the feed's MaxKeep already applies to all its items.
+func (c *Conf) SourceLimits(fmFeed string, src Source) Limits {
+	res := c.FeedLimits(fmFeed).override(src.Limits)
+	res.MaxKeep = src.MaxKeep
+	return res
+}
+
+// validate checks system, feed and source limits, called after defaults are set
+func (c *Conf) validate() error {
+	check := func(l Limits) error {
+		switch {
+		case l.MaxAge < 0:
+			return errors.Errorf("negative max_age %s", l.MaxAge)
+		case l.MaxPerFetch < 0:
+			return errors.Errorf("negative max_per_fetch %d", l.MaxPerFetch)
+		case l.MaxKeep < 0:
+			return errors.Errorf("negative max_keep %d", l.MaxKeep)
+		case l.Update < 0:
+			return errors.Errorf("negative update %s", l.Update)
+		}
+		return nil
+	}
+
+	if c.System.MaxTotal < 0 || c.System.Concurrent < 0 || c.System.HTTPResponseTimeout < 0 {
+		return errors.New("system: negative max_total, concurrent or http_response_timeout")
+	}
+	if c.System.TelegramRate.Global < 0 || c.System.TelegramRate.PerChat < 0 {
+		return errors.New("system: negative telegram_rate")
+	}
+	if err := check(Limits{MaxAge: c.System.MaxAge, MaxPerFetch: c.System.MaxItems, MaxKeep: c.System.MaxKeepInDB,
+		Update: c.System.UpdateInterval}); err != nil {
+		return errors.Wrap(err, "system")
+	}
+	for name, fc := range c.Feeds {
+		if err := checkFeedName(name); err != nil {
+			return err
+		}
+		if err := check(fc.Limits); err != nil {
+			return errors.Wrapf(err, "feed %s", name)
+		}
+		if fc.NearDup.Distance != nil && (*fc.NearDup.Distance < 0 || *fc.NearDup.Distance > 64) {
+			return errors.Errorf("feed %s: near_dup distance %d out of 0..64", name, *fc.NearDup.Distance)
+		}
+		if err := fc.Scoring.validate(); err != nil {
+			return errors.Wrapf(err, "feed %s, scoring", name)
+		}
+		for _, l := range fc.Languages {
+			if !lang.Supported(l) {
+				return errors.Errorf("feed %s: unsupported language %q", name, l)
+			}
+		}
+		if lim := c.FeedLimits(name); lim.MaxKeep < lim.MaxPerFetch {
+			return errors.Errorf("feed %s: max_keep %d is less than max_per_fetch %d", name, lim.MaxKeep, lim.MaxPerFetch)
+		}
+		for _, src := range fc.Sources {
+			if err := check(src.Limits); err != nil {
+				return errors.Wrapf(err, "feed %s, source %s", name, src.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// LimitsReport returns effective limits of all feeds and sources, one per line
+func (c *Conf) LimitsReport() string {
+	names := make([]string, 0, len(c.Feeds))
+	for name := range c.Feeds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "feed %s: %s\n", name, c.FeedLimits(name))
+		for _, src := range c.Feeds[name].Sources {
+			fmt.Fprintf(&sb, "  source %s: %s\n", src.Name, c.SourceLimits(name, src))
+		}
+	}
+	return sb.String()
+}
+
+// override replaces values with non-zero values of other
+func (l Limits) override(other Limits) Limits {
+	if other.MaxAge != 0 {
+		l.MaxAge = other.MaxAge
+	}
+	if other.MaxPerFetch != 0 {
+		l.MaxPerFetch = other.MaxPerFetch
+	}
+	if other.MaxKeep != 0 {
+		l.MaxKeep = other.MaxKeep
+	}
+	if other.Update != 0 {
+		l.Update = other.Update
+	}
+	return l
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Post defines rich telegram posts of the feed, plain text messages by default
+type Post struct {
+	Photo      bool     `yaml:"photo"`       // send image of the item as photo, message is the caption
+	OGImage    bool     `yaml:"og_image"`    // look for og:image of the link if the item has no image
+	NoPreview  bool     `yaml:"no_preview"`  // disable link preview of text messages
+	PreviewURL string   `yaml:"preview_url"` // link, comments or image, first link of the message if empty
+	Buttons    []string `yaml:"buttons"`     // inline buttons, open, discussion and source
+}
+
+// post buttons and preview urls
+const (
+	PostLink     = "link"
+	PostOpen     = "open"
+	PostComments = "comments"
+	PostDiscuss  = "discussion"
+	PostSource   = "source"
+	PostImage    = "image"
+)
+
+func (p Post) validate() error {
+	switch p.PreviewURL {
+	case "", PostLink, PostComments, PostImage:
+	default:
+		return errors.Errorf("unknown preview_url %q", p.PreviewURL)
+	}
+	for _, b := range p.Buttons {
+		switch b {
+		case PostOpen, PostDiscuss, PostSource:
+		default:
+			return errors.Errorf("unknown button %q", b)
+		}
+	}
+	return nil
+}
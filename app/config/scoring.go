@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// Scoring defines relevance score of the feed items, the sum of keyword, source, recency and title length weights.
+// Items below the threshold are stored but not sent, with top set only the best items are sent per window.
+type Scoring struct {
+	Enabled       bool               `yaml:"enabled"`
+	Keywords      map[string]float64 `yaml:"keywords"`       // case-insensitive keyword to weight, counts double in title
+	Sources       map[string]float64 `yaml:"sources"`        // source name to priority weight
+	Recency       float64            `yaml:"recency"`        // weight of just published item, down to 0 at recency_window
+	RecencyWindow time.Duration      `yaml:"recency_window"` // 24h by default
+	TitleLen      TitleLen           `yaml:"title_len"`
+	Threshold     *float64           `yaml:"threshold"` // send only items with score at or above, if set
+	Top           int                `yaml:"top"`       // send only top N items per window, if set
+	Window        time.Duration      `yaml:"window"`    // window of top N, each processing cycle if zero
+}
+
+// TitleLen adds weight to items with title length, in characters, within min and max
+type TitleLen struct {
+	Min    int     `yaml:"min"`
+	Max    int     `yaml:"max"` // no upper limit if zero
+	Weight float64 `yaml:"weight"`
+}
+
+// Score calculates relevance score of the item
+func (s Scoring) Score(item feed.Item, now time.Time) float64 {
+	res := s.Sources[item.Source]
+
+	title, desc := strings.ToLower(item.Title), strings.ToLower(string(item.Description))
+	for k, w := range s.Keywords {
+		k = strings.ToLower(k)
+		if strings.Contains(title, k) {
+			res += 2 * w
+			continue
+		}
+		if strings.Contains(desc, k) {
+			res += w
+		}
+	}
+
+	if age := now.Sub(item.DT); s.Recency != 0 && age < s.RecencyWindow {
+		if age < 0 {
+			age = 0
+		}
+		res += s.Recency * float64(s.RecencyWindow-age) / float64(s.RecencyWindow)
+	}
+
+	if l := len([]rune(item.Title)); s.TitleLen.Weight != 0 && l >= s.TitleLen.Min && (s.TitleLen.Max == 0 || l <= s.TitleLen.Max) {
+		res += s.TitleLen.Weight
+	}
+	return res
+}
+
+func (s Scoring) validate() error {
+	switch {
+	case s.RecencyWindow < 0:
+		return errors.Errorf("negative recency_window %s", s.RecencyWindow)
+	case s.Top < 0:
+		return errors.Errorf("negative top %d", s.Top)
+	case s.Window < 0:
+		return errors.Errorf("negative window %s", s.Window)
+	case s.TitleLen.Max != 0 && s.TitleLen.Max < s.TitleLen.Min:
+		return errors.Errorf("title_len max %d is less than min %d", s.TitleLen.Max, s.TitleLen.Min)
+	}
+	return nil
+}
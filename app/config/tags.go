@@ -0,0 +1,63 @@
+package config
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// Tags is a dictionary of feed tags, tag name to the condition assigning it.
+// Conditions are checked against title and description unless fields set.
+type Tags map[string]Match
+
+var (
+	reTagName   = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+	reTagLetter = regexp.MustCompile(`\p{L}`)
+)
+
+// compile validates tag names and precompiles conditions, names are lowercased.
+// Names without letters are rejected, telegram doesn't link such hashtags.
+func (t Tags) compile() error {
+	compiled := make(Tags, len(t))
+	for name, m := range t {
+		if !reTagName.MatchString(name) {
+			return errors.Errorf("bad tag name %q, only letters, digits and _ allowed", name)
+		}
+		if !reTagLetter.MatchString(name) {
+			return errors.Errorf("bad tag name %q, at least one letter required for telegram hashtag", name)
+		}
+		if _, ok := compiled[strings.ToLower(name)]; ok {
+			return errors.Errorf("duplicate tag name %q, names are case-insensitive", name)
+		}
+		if len(m.Fields) == 0 {
+			m.Fields = []string{"title", "description"}
+		}
+		if err := m.compile(); err != nil {
+			return errors.Wrapf(err, "tag %s", name)
+		}
+		compiled[strings.ToLower(name)] = m
+	}
+	for name := range t {
+		delete(t, name)
+	}
+	for name, m := range compiled {
+		t[name] = m
+	}
+	return nil
+}
+
+// Match returns sorted names of all tags matching the item
+func (t Tags) Match(item feed.Item) []string {
+	var res []string
+	for name, m := range t {
+		if m.match(item) {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+func TestTagsCompile(t *testing.T) {
+	kw := Match{Keywords: []string{"go"}}
+	tbl := []struct {
+		name    string
+		tags    Tags
+		wantErr bool
+	}{
+		{name: "valid", tags: Tags{"Go": kw, "rust_lang": kw, "ai2024": kw, "кино": kw}},
+		{name: "case-insensitive duplicate", tags: Tags{"Go": kw, "go": kw}, wantErr: true},
+		{name: "digits only", tags: Tags{"2024": kw}, wantErr: true},
+		{name: "underscore and digits", tags: Tags{"_1": kw}, wantErr: true},
+		{name: "punctuation", tags: Tags{"c++": kw}, wantErr: true},
+		{name: "space", tags: Tags{"go lang": kw}, wantErr: true},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tags.compile()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTagsMatch(t *testing.T) {
+	tags := Tags{"Go": {Keywords: []string{"golang"}}, "Rust": {Keywords: []string{"cargo"}, Fields: []string{"title"}}}
+	if err := tags.compile(); err != nil {
+		t.Fatal(err)
+	}
+	got := tags.Match(feed.Item{Title: "Golang and Cargo", Description: "cargo"})
+	if len(got) != 2 || got[0] != "go" || got[1] != "rust" {
+		t.Errorf("got %v, want [go rust]", got)
+	}
+	got = tags.Match(feed.Item{Title: "news", Description: "cargo"})
+	if len(got) != 0 {
+		t.Errorf("got %v, want no tags", got)
+	}
+}
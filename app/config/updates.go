@@ -0,0 +1,32 @@
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Updates defines how sent telegram posts follow changes of their items
+type Updates struct {
+	Edit     bool          `yaml:"edit"`     // edit posts of items with changed title or link
+	Vanished string        `yaml:"vanished"` // delete or annotate posts of items gone from the source, kept if empty
+	Grace    time.Duration `yaml:"grace"`    // how long after sending vanished items are looked for, 24h by default
+}
+
+// vanished items policies
+const (
+	VanishedDelete   = "delete"
+	VanishedAnnotate = "annotate"
+)
+
+func (u Updates) validate() error {
+	switch u.Vanished {
+	case "", VanishedDelete, VanishedAnnotate:
+	default:
+		return errors.Errorf("unknown vanished %q", u.Vanished)
+	}
+	if u.Grace < 0 {
+		return errors.Errorf("negative grace %s", u.Grace)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Window is a time of day range on some days of week, when telegram messages are delivered
+type Window struct {
+	Days []string `yaml:"days"` // mon, tue, ... or ranges like mon-fri, every day if empty
+	From string   `yaml:"from"` // 08:00
+	To   string   `yaml:"to"`   // 20:00, next day if not after from
+
+	days     [7]bool // by time.Weekday
+	from, to int     // minutes since midnight
+}
+
+// off hours policies
+const (
+	OffHoursHold   = "hold"
+	OffHoursSilent = "silent"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// start returns start of the window occurrence containing t, zero if t is out of the window
+func (w Window) start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	// today's occurrence, then yesterday's one lasting past midnight
+	for _, shift := range []int{0, -1} {
+		if !w.days[(int(t.Weekday())+7+shift)%7] {
+			continue
+		}
+		from := time.Date(y, m, d+shift, 0, w.from, 0, 0, t.Location())
+		to := time.Date(y, m, d+shift, 0, w.to, 0, 0, t.Location())
+		if w.to <= w.from {
+			to = time.Date(y, m, d+shift+1, 0, w.to, 0, 0, t.Location())
+		}
+		if !t.Before(from) && t.Before(to) {
+			return from
+		}
+	}
+	return time.Time{}
+}
+
+func (w *Window) compile() (err error) {
+	if w.from, err = parseClock(w.From); err != nil {
+		return errors.Wrap(err, "bad from")
+	}
+	if w.to, err = parseClock(w.To); err != nil {
+		return errors.Wrap(err, "bad to")
+	}
+	if len(w.Days) == 0 {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+		return nil
+	}
+	for _, d := range w.Days {
+		first, last, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(d)), "-")
+		if !isRange {
+			last = first
+		}
+		fd, ok1 := weekdays[first]
+		ld, ok2 := weekdays[last]
+		if !ok1 || !ok2 {
+			return errors.Errorf("bad day %q", d)
+		}
+		for i := fd; ; i = (i + 1) % 7 {
+			w.days[i] = true
+			if i == ld {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock parses "15:04" to minutes since midnight, "24:00" allowed as the end of day
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, errors.Errorf("bad time %q, expected like 08:00", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,103 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tbl := []struct {
+		spec string
+		ok   bool
+	}{
+		{"* * * * *", true},
+		{"0 */3 * * *", true},
+		{"0,30 8-20 * * mon", false},
+		{"0,30 8-20 * * 1-5", true},
+		{"5/10 * * * *", true},
+		{"0 0 1,15 * 0,7", true},
+		{"* * * *", false},
+		{"* * * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"5-1 * * * *", false},
+		{"*/0 * * * *", false},
+		{"1-x * * * *", false},
+		{"", false},
+	}
+	for _, tt := range tbl {
+		s, err := Parse(tt.spec)
+		if (err == nil) != tt.ok {
+			t.Errorf("Parse(%q) error %v, want ok %v", tt.spec, err, tt.ok)
+			continue
+		}
+		if err == nil && s.String() != tt.spec {
+			t.Errorf("String() = %q, want %q", s.String(), tt.spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skip("no tz data", err)
+	}
+	utc := func(s string) time.Time {
+		res, e := time.Parse("2006-01-02 15:04", s)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return res
+	}
+	local := func(s string) time.Time {
+		res, e := time.ParseInLocation("2006-01-02 15:04", s, kyiv)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return res
+	}
+	tbl := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"next minute", "* * * * *", utc("2024-05-01 10:00").Add(30 * time.Second), utc("2024-05-01 10:01")},
+		{"strictly after", "0 * * * *", utc("2024-05-01 10:00"), utc("2024-05-01 11:00")},
+		{"steps", "0 */3 * * *", utc("2024-05-01 10:00"), utc("2024-05-01 12:00")},
+		{"start with step", "5/20 * * * *", utc("2024-05-01 10:30"), utc("2024-05-01 10:45")},
+		{"next day", "30 8 * * *", utc("2024-05-01 09:00"), utc("2024-05-02 08:30")},
+		{"next year", "0 0 1 1 *", utc("2024-05-01 09:00"), utc("2025-01-01 00:00")},
+		{"day of week", "0 9 * * 1-5", utc("2024-05-03 10:00"), utc("2024-05-06 09:00")}, // fri to mon
+		{"sunday as 7", "0 9 * * 7", utc("2024-05-01 10:00"), utc("2024-05-05 09:00")},
+		{"dom or dow", "0 0 15 * 1", utc("2024-05-01 10:00"), utc("2024-05-06 00:00")}, // monday before the 15th
+		{"dom or dow, dom first", "0 0 3 * 1", utc("2024-05-01 10:00"), utc("2024-05-03 00:00")},
+		{"dom with any dow", "0 0 15 * *", utc("2024-05-01 10:00"), utc("2024-05-15 00:00")},
+		{"dow with any dom", "0 0 * * 1", utc("2024-05-01 10:00"), utc("2024-05-06 00:00")},
+		{"leap day", "0 0 29 2 *", utc("2024-05-01 10:00"), utc("2028-02-29 00:00")},
+		{"february 30", "0 0 30 2 *", utc("2024-05-01 10:00"), time.Time{}},
+		{"31st skips short months", "0 0 31 * *", utc("2024-04-01 00:00"), utc("2024-05-31 00:00")},
+		{"dst gap skipped", "30 3 * * *", local("2024-03-31 02:00"), local("2024-04-01 03:30")},
+		{"dst gap next hour", "0 * * * *", local("2024-03-31 02:30"), local("2024-03-31 04:00")},
+		{"dst overlap once", "30 3 * * *", local("2024-10-27 02:00"), local("2024-10-27 03:30")},
+		{"in location", "0 9 * * *", local("2024-05-01 10:00"), local("2024-05-02 09:00")},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := s.Next(tt.from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+			if !got.IsZero() && got.Location() != tt.from.Location() {
+				t.Errorf("location %s, want %s", got.Location(), tt.from.Location())
+			}
+		})
+	}
+}
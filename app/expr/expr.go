@@ -0,0 +1,130 @@
+// Package expr implements small typed expression language to filter feed items.
+// Expressions are compiled and type-checked once and evaluated over Env, they can't do anything
+// besides reading the item and calling the built-in functions.
+//
+//	contains(description, "generics") && source != "hacker-news" && age() > 2h
+//
+// Types are string, number, bool, duration (literals like 30m, 2h, 1d, 1w) and list of strings.
+// Operators: ||, &&, !, (or, and, not), ==, !=, <, <=, >, >= and "in".
+package expr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// Env is an evaluation environment, item with its source metadata
+type Env struct {
+	Item      feed.Item
+	Feed      string
+	Source    string // source name, Item.Source if empty
+	SourceURL string
+	Now       time.Time // current time for age(), time.Now() if zero
+}
+
+// Program is a compiled expression
+type Program struct {
+	src  string
+	root node
+}
+
+// Compile parses and type-checks expression, it must be bool
+func Compile(src string) (*Program, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("%d: unexpected %q", tok.pos+1, tok.text)
+	}
+	if root.typ != typeBool {
+		return nil, fmt.Errorf("expression is %s, should be bool", root.typ)
+	}
+	return &Program{src: src, root: root}, nil
+}
+
+// Match evaluates expression for the env
+func (p *Program) Match(env Env) bool {
+	if env.Now.IsZero() {
+		env.Now = time.Now()
+	}
+	if env.Source == "" {
+		env.Source = env.Item.Source
+	}
+	return p.root.eval(&env).(bool)
+}
+
+// String returns source of the expression
+func (p *Program) String() string {
+	return p.src
+}
+
+type valueType int
+
+const (
+	typeBool valueType = iota + 1
+	typeString
+	typeNumber
+	typeDuration
+	typeList
+)
+
+func (t valueType) String() string {
+	switch t {
+	case typeBool:
+		return "bool"
+	case typeString:
+		return "string"
+	case typeNumber:
+		return "number"
+	case typeDuration:
+		return "duration"
+	case typeList:
+		return "list"
+	}
+	return "unknown"
+}
+
+// node is a type-checked expression, eval returns bool, string, float64, time.Duration or []string
+type node struct {
+	typ  valueType
+	eval func(env *Env) any
+}
+
+// variables available in expressions
+var variables = map[string]node{
+	"title":       {typeString, func(env *Env) any { return env.Item.Title }},
+	"description": {typeString, func(env *Env) any { return string(env.Item.Description) }},
+	"content":     {typeString, func(env *Env) any { return string(env.Item.Content) }},
+	"author":      {typeString, func(env *Env) any { return env.Item.Author }},
+	"link":        {typeString, func(env *Env) any { return env.Item.Link }},
+	"guid":        {typeString, func(env *Env) any { return env.Item.GUID }},
+	"comments":    {typeString, func(env *Env) any { return env.Item.Comments }},
+	"categories":  {typeList, func(env *Env) any { return env.Item.Categories }},
+	"tags":        {typeList, func(env *Env) any { return env.Item.Tags }},
+	"feed":        {typeString, func(env *Env) any { return env.Feed }},
+	"source":      {typeString, func(env *Env) any { return env.Source }},
+	"source_url":  {typeString, func(env *Env) any { return env.SourceURL }},
+	"lang":        {typeString, func(env *Env) any { return env.Item.Lang }},
+	"score":       {typeNumber, func(env *Env) any { return env.Item.Score }},
+	// arXiv metadata, empty for other items
+	"arxiv_id":         {typeString, func(env *Env) any { return arxiv(env).ID }},
+	"arxiv_announce":   {typeString, func(env *Env) any { return arxiv(env).Announce }},
+	"arxiv_primary":    {typeString, func(env *Env) any { return arxiv(env).Primary }},
+	"arxiv_crosslists": {typeList, func(env *Env) any { return arxiv(env).CrossLists }},
+	"arxiv_authors":    {typeList, func(env *Env) any { return arxiv(env).Authors }},
+}
+
+func arxiv(env *Env) feed.Arxiv {
+	if env.Item.Arxiv == nil {
+		return feed.Arxiv{}
+	}
+	return *env.Item.Arxiv
+}
@@ -0,0 +1,134 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+func TestCompileErrors(t *testing.T) {
+	tbl := []struct {
+		src, err string
+	}{
+		{"", "unexpected end of expression"},
+		{"title", "expression is string, should be bool"},
+		{`title == "a`, "unterminated string"},
+		{`title == 'a\`, "unterminated string"},
+		{"title == 5", "can't compare string and number"},
+		{"age() > 2x", "unknown duration unit"},
+		{"score > 1.2.3", "bad number"},
+		{"unknown == 1", `unknown identifier "unknown"`},
+		{"nofunc()", `unknown function "nofunc"`},
+		{"title && true", "&& needs bool operands, got string and bool"},
+		{"true or 1", "or needs bool operands"},
+		{"not title", "not needs bool operand, got string"},
+		{"tags == tags", "== not supported for list"},
+		{"true < false", "< not supported for bool"},
+		{`tags in "x"`, "in needs string on the left"},
+		{"(true", "expected ), got"},
+		{"true)", `unexpected ")"`},
+		{"true # false", `unexpected '#'`},
+		{"contains(title)", "expects (string, string) or (list, string)"},
+		{"matches(title, lower(title))", "regex should be a string literal"},
+		{`matches(title, "(")`, "missing closing )"},
+		{"age(1)", "expects 0 arguments, got 1"},
+		{"lower(1) == 'a'", "argument 1 should be string, got number"},
+		{"len(1) > 0", "expects string or list"},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Compile(tt.src)
+			if err == nil {
+				t.Fatalf("no error, want %q", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %q, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	env := Env{
+		Item: feed.Item{Title: "Go 1.23 Released", Description: "Range over <b>function</b> iterators",
+			Link: "https://www.Go.dev/blog/go1.23", Author: "gopher", Categories: []string{"Go", "Release"},
+			Tags: []string{"go"}, Score: 7.5, DT: now.Add(-3 * time.Hour), Source: "go-blog",
+			Arxiv: &feed.Arxiv{ID: "2401.12345", Primary: "cs.CV", Authors: []string{"A. One"}}},
+		Feed: "news", SourceURL: "https://go.dev/blog/feed.atom", Now: now,
+	}
+	tbl := []struct {
+		src  string
+		want bool
+	}{
+		{"true", true},
+		{"false", false},
+		{`title == "Go 1.23 Released"`, true},
+		{`title != 'Go 1.23 Released'`, false},
+		{`title < "H"`, true},
+		{`contains(title, "released")`, true},
+		{`contains(description, "generics")`, false},
+		{`contains(categories, "release")`, true},
+		{`"GO" in categories`, true},
+		{`"Go" in title`, true},
+		{`"go" in title`, false},
+		{`matches(title, "^Go \\d+\\.\\d+")`, true},
+		{`matches(link, "rust")`, false},
+		{"age() > 2h", true},
+		{"age() > 1d", false},
+		{"age() >= 180m && age() <= 3h", true},
+		{"age() < 0.5w", true},
+		{"score >= 7.5", true},
+		{"score > 7.5", false},
+		{`domain() == "go.dev"`, true},
+		{`domain(source_url) == domain()`, true},
+		{"len(tags) == 1 && len(author) == 6", true},
+		{`lower(author) == "gopher"`, true},
+		{`source == "go-blog" && feed == "news"`, true},
+		{`arxiv_primary == "cs.CV" && "a. one" in arxiv_authors`, true},
+		{`len(arxiv_crosslists) == 0`, true},
+		{"!false", true},
+		{"not not true", true},
+		{"false || true && false", false},
+		{"(false || true) && true", true},
+		{"false or true and true", true},
+		{"true == (score > 1)", true},
+		{`title == "a\"b" || description == 'it\'s'`, false},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.src, func(t *testing.T) {
+			p, err := Compile(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Match(env); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+			if p.String() != tt.src {
+				t.Errorf("String() = %q, want %q", p.String(), tt.src)
+			}
+		})
+	}
+}
+
+func TestMatchDefaults(t *testing.T) {
+	p, err := Compile(`source == "s1" && age() < 1m`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Match(Env{Item: feed.Item{Source: "s1", DT: time.Now()}}) {
+		t.Error("item source and current time not used by default")
+	}
+	if p.Match(Env{Item: feed.Item{Source: "s1", DT: time.Now()}, Source: "s2"}) {
+		t.Error("env source not used")
+	}
+	arxiv, err := Compile(`arxiv_id == ""`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !arxiv.Match(Env{}) {
+		t.Error("arxiv fields of other items not empty")
+	}
+}
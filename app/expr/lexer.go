@@ -0,0 +1,150 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string // raw text, unquoted for strings
+	num  float64
+	dur  time.Duration
+	pos  int
+}
+
+// operators, longest first
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!"}
+
+// lex splits source into tokens
+func lex(src string) ([]token, error) {
+	var res []token
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			res = append(res, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			res = append(res, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case r == ',':
+			res = append(res, token{kind: tokComma, text: ",", pos: i})
+			i++
+		case r == '"' || r == '\'':
+			tok, n, err := lexString(rs[i:], i)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, tok)
+			i += n
+		case unicode.IsDigit(r):
+			tok, n, err := lexNumber(rs[i:], i)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, tok)
+			i += n
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			res = append(res, token{kind: tokIdent, text: string(rs[start:i]), pos: start})
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(string(rs[i:]), o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("%d: unexpected %q", i+1, r)
+			}
+			res = append(res, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(res, token{kind: tokEOF, pos: len(rs)}), nil
+}
+
+// lexString reads quoted string with \-escapes, returns token and number of runes consumed
+func lexString(rs []rune, pos int) (token, int, error) {
+	quote := rs[0]
+	var sb strings.Builder
+	for i := 1; i < len(rs); i++ {
+		switch rs[i] {
+		case '\\':
+			if i+1 >= len(rs) {
+				return token{}, 0, fmt.Errorf("%d: unterminated string", pos+1)
+			}
+			i++
+			switch rs[i] {
+			case 'n':
+				sb.WriteRune('\n')
+			case 't':
+				sb.WriteRune('\t')
+			default:
+				sb.WriteRune(rs[i])
+			}
+		case quote:
+			return token{kind: tokString, text: sb.String(), pos: pos}, i + 1, nil
+		default:
+			sb.WriteRune(rs[i])
+		}
+	}
+	return token{}, 0, fmt.Errorf("%d: unterminated string", pos+1)
+}
+
+// lexNumber reads number or duration like 2h, 1.5d, 30m
+func lexNumber(rs []rune, pos int) (token, int, error) {
+	i := 0
+	for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+		i++
+	}
+	numText := string(rs[:i])
+	num, err := strconv.ParseFloat(numText, 64)
+	if err != nil {
+		return token{}, 0, fmt.Errorf("%d: bad number %q", pos+1, numText)
+	}
+
+	unitStart := i
+	for i < len(rs) && unicode.IsLetter(rs[i]) {
+		i++
+	}
+	unit := string(rs[unitStart:i])
+	if unit == "" {
+		return token{kind: tokNumber, text: numText, num: num, pos: pos}, i, nil
+	}
+
+	units := map[string]time.Duration{
+		"ms": time.Millisecond, "s": time.Second, "m": time.Minute, "h": time.Hour,
+		"d": 24 * time.Hour, "w": 7 * 24 * time.Hour,
+	}
+	mult, ok := units[unit]
+	if !ok {
+		return token{}, 0, fmt.Errorf("%d: unknown duration unit %q", pos+1, unit)
+	}
+	return token{kind: tokDuration, text: string(rs[:i]), dur: time.Duration(num * float64(mult)), pos: pos}, i, nil
+}
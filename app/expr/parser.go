@@ -0,0 +1,351 @@
+package expr
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// parser is a recursive descent parser making type-checked nodes
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isOp checks if the next token is one of operators, keywords like "and" are accepted as operators
+func (p *parser) isOp(ops ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokOp && tok.kind != tokIdent {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return node{}, err
+	}
+	for p.isOp("||", "or") {
+		tok := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return node{}, err
+		}
+		if left.typ != typeBool || right.typ != typeBool {
+			return node{}, fmt.Errorf("%d: %s needs bool operands, got %s and %s", tok.pos+1, tok.text, left.typ, right.typ)
+		}
+		l, r := left.eval, right.eval
+		left = node{typeBool, func(env *Env) any { return l(env).(bool) || r(env).(bool) }}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return node{}, err
+	}
+	for p.isOp("&&", "and") {
+		tok := p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return node{}, err
+		}
+		if left.typ != typeBool || right.typ != typeBool {
+			return node{}, fmt.Errorf("%d: %s needs bool operands, got %s and %s", tok.pos+1, tok.text, left.typ, right.typ)
+		}
+		l, r := left.eval, right.eval
+		left = node{typeBool, func(env *Env) any { return l(env).(bool) && r(env).(bool) }}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOp("!", "not") {
+		tok := p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return node{}, err
+		}
+		if operand.typ != typeBool {
+			return node{}, fmt.Errorf("%d: %s needs bool operand, got %s", tok.pos+1, tok.text, operand.typ)
+		}
+		o := operand.eval
+		return node{typeBool, func(env *Env) any { return !o(env).(bool) }}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return node{}, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=", "in") {
+		return left, nil
+	}
+	tok := p.next()
+	right, err := p.parsePrimary()
+	if err != nil {
+		return node{}, err
+	}
+
+	if tok.text == "in" {
+		return inNode(tok, left, right)
+	}
+	if left.typ != right.typ {
+		return node{}, fmt.Errorf("%d: can't compare %s and %s", tok.pos+1, left.typ, right.typ)
+	}
+	if left.typ == typeList || (left.typ == typeBool && tok.text != "==" && tok.text != "!=") {
+		return node{}, fmt.Errorf("%d: %s not supported for %s", tok.pos+1, tok.text, left.typ)
+	}
+	l, r, op := left.eval, right.eval, tok.text
+	return node{typeBool, func(env *Env) any { return compare(op, l(env), r(env)) }}, nil
+}
+
+// inNode makes "a in b" for string in list (case-insensitive) and substring in string
+func inNode(tok token, left, right node) (node, error) {
+	if left.typ != typeString || (right.typ != typeString && right.typ != typeList) {
+		return node{}, fmt.Errorf("%d: in needs string on the left and string or list on the right", tok.pos+1)
+	}
+	l, r := left.eval, right.eval
+	if right.typ == typeList {
+		return node{typeBool, func(env *Env) any { return listContains(r(env).([]string), l(env).(string)) }}, nil
+	}
+	return node{typeBool, func(env *Env) any { return strings.Contains(r(env).(string), l(env).(string)) }}, nil
+}
+
+func compare(op string, l, r any) bool {
+	var c int // -1, 0, 1
+	switch lv := l.(type) {
+	case bool:
+		if lv == r.(bool) {
+			return op == "=="
+		}
+		return op == "!="
+	case string:
+		c = strings.Compare(lv, r.(string))
+	case float64:
+		c = cmpOrdered(lv, r.(float64))
+	case time.Duration:
+		c = cmpOrdered(lv, r.(time.Duration))
+	}
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func cmpOrdered[T float64 | time.Duration](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		v := tok.text
+		return node{typeString, func(*Env) any { return v }}, nil
+	case tokNumber:
+		v := tok.num
+		return node{typeNumber, func(*Env) any { return v }}, nil
+	case tokDuration:
+		v := tok.dur
+		return node{typeDuration, func(*Env) any { return v }}, nil
+	case tokLParen:
+		res, err := p.parseOr()
+		if err != nil {
+			return node{}, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return node{}, fmt.Errorf("%d: expected ), got %q", closing.pos+1, closing.text)
+		}
+		return res, nil
+	case tokIdent:
+		if p.peek().kind == tokLParen {
+			return p.parseCall(tok)
+		}
+		switch tok.text {
+		case "true", "false":
+			v := tok.text == "true"
+			return node{typeBool, func(*Env) any { return v }}, nil
+		}
+		if v, ok := variables[tok.text]; ok {
+			return v, nil
+		}
+		return node{}, fmt.Errorf("%d: unknown identifier %q", tok.pos+1, tok.text)
+	case tokEOF:
+		return node{}, fmt.Errorf("%d: unexpected end of expression", tok.pos+1)
+	}
+	return node{}, fmt.Errorf("%d: unexpected %q", tok.pos+1, tok.text)
+}
+
+// parseCall parses function call, name token already consumed
+func (p *parser) parseCall(name token) (node, error) {
+	p.next() // (
+	var args []node
+	var argTokens []token
+	if p.peek().kind != tokRParen {
+		for {
+			argTokens = append(argTokens, p.peek())
+			arg, err := p.parseOr()
+			if err != nil {
+				return node{}, err
+			}
+			args = append(args, arg)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if closing := p.next(); closing.kind != tokRParen {
+		return node{}, fmt.Errorf("%d: expected ), got %q", closing.pos+1, closing.text)
+	}
+
+	fn, ok := functions[name.text]
+	if !ok {
+		return node{}, fmt.Errorf("%d: unknown function %q", name.pos+1, name.text)
+	}
+	res, err := fn(args, argTokens)
+	if err != nil {
+		return node{}, fmt.Errorf("%d: %s: %w", name.pos+1, name.text, err)
+	}
+	return res, nil
+}
+
+// functions make nodes from type-checked arguments, argument tokens are used for constant arguments
+var functions = map[string]func(args []node, argTokens []token) (node, error){
+	// contains(string, sub) is case-insensitive substring, contains(list, s) is case-insensitive membership
+	"contains": func(args []node, _ []token) (node, error) {
+		if err := checkArgs(args, typeString, typeString); err == nil {
+			s, sub := args[0].eval, args[1].eval
+			return node{typeBool, func(env *Env) any {
+				return strings.Contains(strings.ToLower(s(env).(string)), strings.ToLower(sub(env).(string)))
+			}}, nil
+		}
+		if err := checkArgs(args, typeList, typeString); err != nil {
+			return node{}, fmt.Errorf("expects (string, string) or (list, string)")
+		}
+		l, v := args[0].eval, args[1].eval
+		return node{typeBool, func(env *Env) any { return listContains(l(env).([]string), v(env).(string)) }}, nil
+	},
+	// matches(string, "regex") with the regex compiled once
+	"matches": func(args []node, argTokens []token) (node, error) {
+		if err := checkArgs(args, typeString, typeString); err != nil {
+			return node{}, err
+		}
+		if argTokens[1].kind != tokString {
+			return node{}, fmt.Errorf("regex should be a string literal")
+		}
+		re, err := regexp.Compile(argTokens[1].text)
+		if err != nil {
+			return node{}, err
+		}
+		s := args[0].eval
+		return node{typeBool, func(env *Env) any { return re.MatchString(s(env).(string)) }}, nil
+	},
+	// age() is duration since the item published
+	"age": func(args []node, _ []token) (node, error) {
+		if err := checkArgs(args); err != nil {
+			return node{}, err
+		}
+		return node{typeDuration, func(env *Env) any { return env.Now.Sub(env.Item.DT) }}, nil
+	},
+	// domain() is the item link's host without www., domain(s) for any url
+	"domain": func(args []node, _ []token) (node, error) {
+		if len(args) == 0 {
+			return node{typeString, func(env *Env) any { return domain(env.Item.Link) }}, nil
+		}
+		if err := checkArgs(args, typeString); err != nil {
+			return node{}, err
+		}
+		s := args[0].eval
+		return node{typeString, func(env *Env) any { return domain(s(env).(string)) }}, nil
+	},
+	// len(string) is number of characters, len(list) is number of elements
+	"len": func(args []node, _ []token) (node, error) {
+		if len(args) == 1 && args[0].typ == typeList {
+			l := args[0].eval
+			return node{typeNumber, func(env *Env) any { return float64(len(l(env).([]string))) }}, nil
+		}
+		if err := checkArgs(args, typeString); err != nil {
+			return node{}, fmt.Errorf("expects string or list")
+		}
+		s := args[0].eval
+		return node{typeNumber, func(env *Env) any { return float64(len([]rune(s(env).(string)))) }}, nil
+	},
+	"lower": func(args []node, _ []token) (node, error) {
+		if err := checkArgs(args, typeString); err != nil {
+			return node{}, err
+		}
+		s := args[0].eval
+		return node{typeString, func(env *Env) any { return strings.ToLower(s(env).(string)) }}, nil
+	},
+}
+
+func checkArgs(args []node, types ...valueType) error {
+	if len(args) != len(types) {
+		return fmt.Errorf("expects %d arguments, got %d", len(types), len(args))
+	}
+	for i, t := range types {
+		if args[i].typ != t {
+			return fmt.Errorf("argument %d should be %s, got %s", i+1, t, args[i].typ)
+		}
+	}
+	return nil
+}
+
+func listContains(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func domain(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
@@ -0,0 +1,69 @@
+package feed
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Arxiv is metadata of arXiv item, extracted from the link, description and arxiv: and dc: fields
+type Arxiv struct {
+	ID         string   `json:"id"`                   // like 2401.12345, without version
+	Version    string   `json:"version,omitempty"`    // like v2
+	Announce   string   `json:"announce,omitempty"`   // new, cross, replace or replace-cross
+	Authors    []string `json:"authors,omitempty"`    // in the listed order
+	Primary    string   `json:"primary,omitempty"`    // primary category, like cs.CV
+	CrossLists []string `json:"crosslists,omitempty"` // other categories
+	Abstract   string   `json:"abstract,omitempty"`   // plain text
+}
+
+var (
+	reArxivID       = regexp.MustCompile(`arxiv\.org/(?:abs|pdf)/([a-z\-]+(?:\.[A-Z]{2})?/\d{7}|\d{4}\.\d{4,5})(v\d+)?`)
+	reArxivOAI      = regexp.MustCompile(`^oai:arXiv\.org:([a-z\-]+(?:\.[A-Z]{2})?/\d{7}|\d{4}\.\d{4,5})(v\d+)?$`)
+	reArxivAnnounce = regexp.MustCompile(`Announce Type:\s*([a-z\-]+)`)
+	reArxivAbstract = regexp.MustCompile(`(?s)Abstract:\s*(.*)`)
+	reHTMLTag       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// PDFLink returns link to pdf of the paper
+func (a Arxiv) PDFLink() string {
+	return "https://arxiv.org/pdf/" + a.ID + a.Version
+}
+
+// ParseArxiv extracts arXiv metadata from the item, returns nil if it's not an arXiv item
+func ParseArxiv(item Item) *Arxiv {
+	res := Arxiv{}
+	if m := reArxivOAI.FindStringSubmatch(item.GUID); m != nil {
+		res.ID, res.Version = m[1], m[2]
+	} else if m := reArxivID.FindStringSubmatch(item.Link); m != nil {
+		res.ID, res.Version = m[1], m[2]
+	}
+	if res.ID == "" {
+		return nil
+	}
+
+	desc := html.UnescapeString(reHTMLTag.ReplaceAllString(string(item.Description), " "))
+	res.Announce = strings.TrimSpace(item.ArxivAnnounce)
+	if m := reArxivAnnounce.FindStringSubmatch(desc); res.Announce == "" && m != nil {
+		res.Announce = m[1]
+	}
+	if m := reArxivAbstract.FindStringSubmatch(desc); m != nil {
+		res.Abstract = strings.Join(strings.Fields(m[1]), " ")
+	}
+
+	authors := item.Creator
+	if authors == "" {
+		authors = item.Author
+	}
+	for _, a := range strings.Split(html.UnescapeString(reHTMLTag.ReplaceAllString(authors, "")), ",") {
+		if a = strings.Join(strings.Fields(a), " "); a != "" {
+			res.Authors = append(res.Authors, a)
+		}
+	}
+
+	if len(item.Categories) > 0 {
+		res.Primary = item.Categories[0]
+		res.CrossLists = item.Categories[1:]
+	}
+	return &res
+}
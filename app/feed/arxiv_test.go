@@ -0,0 +1,73 @@
+package feed
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArxiv(t *testing.T) {
+	data, err := os.ReadFile("testdata/arxiv.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rss, err := parseFeedContent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rss, err = rss.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	tbl := []struct {
+		want     *Arxiv
+		abstract string // prefix of the abstract
+		pdf      string
+	}{
+		{want: &Arxiv{ID: "1706.03762", Version: "v7", Announce: "replace", Primary: "cs.CL", CrossLists: []string{"cs.LG"},
+			Authors: []string{"Ashish Vaswani", "Noam Shazeer", "Niki Parmar", "Jakob Uszkoreit", "Llion Jones",
+				"Aidan N. Gomez", "Lukasz Kaiser", "Illia Polosukhin"}},
+			abstract: "The dominant sequence transduction models are based", pdf: "https://arxiv.org/pdf/1706.03762v7"},
+		{want: &Arxiv{ID: "1512.03385", Version: "v1", Announce: "new", Primary: "cs.CV", CrossLists: []string{},
+			Authors: []string{"Kaiming He", "Xiangyu Zhang", "Shaoqing Ren", "Jian Sun"}},
+			abstract: "Deeper neural networks are more difficult to train.", pdf: "https://arxiv.org/pdf/1512.03385v1"},
+		{want: &Arxiv{ID: "hep-th/9711200", Version: "v3", Authors: []string{"Juan M. Maldacena"}},
+			pdf: "https://arxiv.org/pdf/hep-th/9711200v3"},
+		{want: &Arxiv{ID: "math/0211159", Version: "v1", Primary: "math.DG", CrossLists: []string{"math.MG"},
+			Authors: []string{"Grisha Perelman"}}, abstract: "We present a monotonic expression for the Ricci flow",
+			pdf: "https://arxiv.org/pdf/math/0211159v1"},
+		{want: nil},
+	}
+	if len(rss.ItemList) != len(tbl) {
+		t.Fatalf("%d items, want %d", len(rss.ItemList), len(tbl))
+	}
+	for i, tt := range tbl {
+		item := rss.ItemList[i]
+		t.Run(item.Title, func(t *testing.T) {
+			got := ParseArxiv(item)
+			if !reflect.DeepEqual(got, item.Arxiv) {
+				t.Errorf("Normalize set %+v, ParseArxiv returns %+v", item.Arxiv, got)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("got %+v for non-arxiv item", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("not parsed")
+			}
+			if !strings.HasPrefix(got.Abstract, tt.abstract) || (tt.abstract == "" && got.Abstract != "") {
+				t.Errorf("abstract %q, want %q...", got.Abstract, tt.abstract)
+			}
+			if got.PDFLink() != tt.pdf {
+				t.Errorf("pdf link %q, want %q", got.PDFLink(), tt.pdf)
+			}
+			got.Abstract = ""
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package feed
 
 import (
 	"html/template"
+	"strings"
 	"time"
 )
 
@@ -22,5 +23,44 @@ type Item struct {
 	Duration    string        `xml:"duration,omitempty"`
 	DurationFmt string        `xml:"-"` // used for ui only in
 	Enclosure   Enclosure     `xml:"enclosure"`
-	Junk        bool          `xml:"-"`
+	Categories  []string      `xml:"category,omitempty"`
+	Tags        []string      `xml:"-"` // names of matched feed tags
+	Lang        string        `xml:"-"` // detected language code, empty if unknown
+	Score       float64       `xml:"-"` // relevance score, set if scoring enabled for the feed
+	Arxiv       *Arxiv        `xml:"-"` // set for arXiv items only
+	Image       string        `xml:"-"` // image url from media thumbnail or content, or image enclosure
+	// Namespaced fields of the source feed
+	Creator       string  `xml:"http://purl.org/dc/elements/1.1/ creator,omitempty"`
+	ArxivAnnounce string  `xml:"http://arxiv.org/schemas/atom announce_type,omitempty"`
+	MediaThumb    *Media  `xml:"http://search.yahoo.com/mrss/ thumbnail,omitempty"`
+	MediaContent  []Media `xml:"http://search.yahoo.com/mrss/ content,omitempty"`
+	Junk          bool    `xml:"-"`
+	JunkReason    string  `xml:"-"` // why the item was marked as junk, i.e. name of the filter rule
+	DuplicateOf   string  `xml:"-"` // link of the original item with the same canonical link
+	SimilarTo     string  `xml:"-"` // link of the original item with nearly the same title and description
+	Source        string  `xml:"-"` // name of the source the item came from
+	Feed          string  `xml:"-"` // name of the feed, set for items merged from multiple feeds only
+}
+
+// Media is media:thumbnail or media:content element of the item
+type Media struct {
+	URL    string `xml:"url,attr"`
+	Medium string `xml:"medium,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+}
+
+// image returns url of the item image, thumbnail first, empty if none
+func (item Item) image() string {
+	if item.MediaThumb != nil && item.MediaThumb.URL != "" {
+		return item.MediaThumb.URL
+	}
+	for _, m := range item.MediaContent {
+		if m.URL != "" && (m.Medium == "image" || strings.HasPrefix(m.Type, "image/")) {
+			return m.URL
+		}
+	}
+	if strings.HasPrefix(item.Enclosure.Type, "image/") {
+		return item.Enclosure.URL
+	}
+	return ""
 }
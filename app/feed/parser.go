@@ -211,6 +211,8 @@ func (rss *Rss2) Normalize() (Rss2, error) {
 		}
 		rss.ItemList[i].Title = strings.ReplaceAll(item.Title, "\n", "")
 		rss.ItemList[i].Title = strings.TrimSpace(rss.ItemList[i].Title)
+		rss.ItemList[i].Arxiv = ParseArxiv(rss.ItemList[i])
+		rss.ItemList[i].Image = rss.ItemList[i].image()
 	}
 	return *rss, nil
 }
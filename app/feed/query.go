@@ -0,0 +1,44 @@
+package feed
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Query defines filters and page of stored items, zero values mean no filtering
+type Query struct {
+	From     time.Time // published at or after
+	To       time.Time // published before
+	Source   string    // source name
+	Tag      string    // one of item tags
+	Text     string    // case-insensitive substring of title or description
+	Offset   int
+	Limit    int
+	SkipJunk bool
+}
+
+// Match checks if the item passes all filters of the query
+func (q Query) Match(item Item) bool {
+	if q.SkipJunk && item.Junk {
+		return false
+	}
+	if q.Source != "" && item.Source != q.Source {
+		return false
+	}
+	if q.Tag != "" && !slices.Contains(item.Tags, q.Tag) {
+		return false
+	}
+	if !q.To.IsZero() && !item.DT.Before(q.To) {
+		return false
+	}
+	if !q.From.IsZero() && item.DT.Before(q.From) {
+		return false
+	}
+	if q.Text == "" {
+		return true
+	}
+	text := strings.ToLower(q.Text)
+	return strings.Contains(strings.ToLower(item.Title), text) ||
+		strings.Contains(strings.ToLower(string(item.Description)), text)
+}
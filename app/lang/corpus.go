@@ -0,0 +1,121 @@
+package lang
+
+// corpus is a sample text of each language with n-gram profile, profiles are built from it on init.
+// Texts are deliberately mixed, general and tech news, to match typical feed items.
+var corpus = map[string]string{
+	"en": `The new version of the language brings generics to the standard library and improves the performance
+of the garbage collector. Developers have been waiting for this release for a long time, and the community
+response has been very positive. In this article we will look at what changed, why it matters and how you can
+start using the new features in your own projects today. The government announced that the budget for the next
+year will include more money for schools and hospitals. Researchers at the university have found a way to train
+large models with less data, which could make the technology cheaper and more accessible for everyone. There is
+still a lot of work to do, but the results of the first experiments are encouraging. We are hiring engineers who
+want to build reliable systems and enjoy working with people from all over the world. Please read the full
+story on our website and share your thoughts with us in the comments below. What do you think about it?
+This is the first time that the company has shown a profit since it was founded, and the shares went up.`,
+
+	"uk": `Нова версія мови програмування додає узагальнені типи до стандартної бібліотеки та покращує швидкодію
+збирача сміття. Розробники давно чекали на цей випуск, і реакція спільноти була дуже позитивною. У цій статті
+ми розглянемо, що змінилося, чому це важливо і як ви можете почати використовувати нові можливості у своїх
+проєктах вже сьогодні. Уряд оголосив, що бюджет на наступний рік передбачає більше коштів для шкіл і лікарень.
+Дослідники університету знайшли спосіб навчати великі моделі на меншій кількості даних, що може зробити
+технологію дешевшою та доступнішою для всіх. Ще є багато роботи, але результати перших експериментів
+обнадійливі. Ми шукаємо інженерів, які хочуть будувати надійні системи і люблять працювати з людьми з усього
+світу. Будь ласка, прочитайте повну історію на нашому сайті та поділіться своїми думками в коментарях.
+Що ви про це думаєте? Це перший раз, коли компанія отримала прибуток відтоді, як її було засновано, і її
+акції зросли. Київ, Львів, Харків і Одеса є великими містами України, де працює багато ІТ-компаній.`,
+
+	"ru": `Новая версия языка программирования добавляет обобщённые типы в стандартную библиотеку и улучшает
+производительность сборщика мусора. Разработчики давно ждали этот выпуск, и реакция сообщества была очень
+положительной. В этой статье мы рассмотрим, что изменилось, почему это важно и как вы можете начать использовать
+новые возможности в своих проектах уже сегодня. Правительство объявило, что бюджет на следующий год предусматривает
+больше денег для школ и больниц. Исследователи университета нашли способ обучать большие модели на меньшем
+количестве данных, что может сделать технологию дешевле и доступнее для всех. Ещё предстоит много работы, но
+результаты первых экспериментов обнадёживают. Мы ищем инженеров, которые хотят строить надёжные системы и любят
+работать с людьми со всего мира. Пожалуйста, прочитайте полную историю на нашем сайте и поделитесь своими
+мыслями в комментариях. Что вы об этом думаете? Это первый раз, когда компания получила прибыль с момента
+своего основания, и её акции выросли. Это было бы интересно, если бы мы смогли это сделать.`,
+
+	"de": `Die neue Version der Sprache bringt Generics in die Standardbibliothek und verbessert die Leistung des
+Garbage Collectors. Entwickler haben lange auf diese Veröffentlichung gewartet, und die Reaktion der Gemeinschaft
+war sehr positiv. In diesem Artikel sehen wir uns an, was sich geändert hat, warum es wichtig ist und wie Sie die
+neuen Funktionen schon heute in Ihren eigenen Projekten nutzen können. Die Regierung hat angekündigt, dass der
+Haushalt für das nächste Jahr mehr Geld für Schulen und Krankenhäuser vorsieht. Forscher der Universität haben
+einen Weg gefunden, große Modelle mit weniger Daten zu trainieren, was die Technologie für alle günstiger und
+zugänglicher machen könnte. Es gibt noch viel zu tun, aber die Ergebnisse der ersten Experimente sind
+ermutigend. Wir suchen Ingenieure, die zuverlässige Systeme bauen wollen und gerne mit Menschen aus der ganzen
+Welt zusammenarbeiten. Bitte lesen Sie die ganze Geschichte auf unserer Webseite und teilen Sie uns Ihre
+Meinung in den Kommentaren mit. Was denken Sie darüber? Das ist das erste Mal, dass die Firma seit ihrer
+Gründung einen Gewinn gemacht hat, und die Aktien sind gestiegen.`,
+
+	"fr": `La nouvelle version du langage apporte les génériques dans la bibliothèque standard et améliore les
+performances du ramasse-miettes. Les développeurs attendaient cette version depuis longtemps, et la réaction de
+la communauté a été très positive. Dans cet article, nous verrons ce qui a changé, pourquoi c'est important et
+comment vous pouvez commencer à utiliser les nouvelles fonctionnalités dans vos propres projets dès aujourd'hui.
+Le gouvernement a annoncé que le budget de l'année prochaine prévoit plus d'argent pour les écoles et les
+hôpitaux. Des chercheurs de l'université ont trouvé un moyen d'entraîner de grands modèles avec moins de
+données, ce qui pourrait rendre la technologie moins chère et plus accessible pour tous. Il reste encore
+beaucoup de travail, mais les résultats des premières expériences sont encourageants. Nous recrutons des
+ingénieurs qui veulent construire des systèmes fiables et qui aiment travailler avec des gens du monde entier.
+Veuillez lire l'histoire complète sur notre site et partagez vos idées avec nous dans les commentaires. Qu'en
+pensez-vous ? C'est la première fois que l'entreprise réalise un bénéfice depuis sa création, et les actions
+ont augmenté.`,
+
+	"es": `La nueva versión del lenguaje trae genéricos a la biblioteca estándar y mejora el rendimiento del
+recolector de basura. Los desarrolladores esperaban este lanzamiento desde hace mucho tiempo, y la respuesta de
+la comunidad ha sido muy positiva. En este artículo veremos qué ha cambiado, por qué es importante y cómo puede
+empezar a usar las nuevas funciones en sus propios proyectos hoy mismo. El gobierno anunció que el presupuesto
+para el próximo año incluirá más dinero para escuelas y hospitales. Los investigadores de la universidad han
+encontrado una forma de entrenar modelos grandes con menos datos, lo que podría hacer que la tecnología sea más
+barata y accesible para todos. Todavía queda mucho trabajo por hacer, pero los resultados de los primeros
+experimentos son alentadores. Estamos contratando ingenieros que quieran construir sistemas fiables y que
+disfruten trabajando con personas de todo el mundo. Por favor, lea la historia completa en nuestro sitio web y
+comparta sus opiniones con nosotros en los comentarios. ¿Qué piensa usted? Es la primera vez que la empresa
+obtiene beneficios desde su fundación, y las acciones subieron.`,
+
+	"it": `La nuova versione del linguaggio porta i generici nella libreria standard e migliora le prestazioni del
+garbage collector. Gli sviluppatori aspettavano questo rilascio da molto tempo e la risposta della comunità è
+stata molto positiva. In questo articolo vedremo cosa è cambiato, perché è importante e come potete iniziare a
+usare le nuove funzionalità nei vostri progetti già oggi. Il governo ha annunciato che il bilancio del prossimo
+anno prevede più soldi per le scuole e gli ospedali. I ricercatori dell'università hanno trovato un modo per
+addestrare grandi modelli con meno dati, il che potrebbe rendere la tecnologia più economica e accessibile per
+tutti. C'è ancora molto lavoro da fare, ma i risultati dei primi esperimenti sono incoraggianti. Stiamo cercando
+ingegneri che vogliano costruire sistemi affidabili e che amino lavorare con persone di tutto il mondo. Per
+favore leggete la storia completa sul nostro sito e condividete le vostre opinioni con noi nei commenti. Che
+cosa ne pensate? È la prima volta che l'azienda realizza un utile dalla sua fondazione, e le azioni sono
+salite.`,
+
+	"pt": `A nova versão da linguagem traz genéricos para a biblioteca padrão e melhora o desempenho do coletor de
+lixo. Os desenvolvedores esperavam por este lançamento há muito tempo, e a resposta da comunidade foi muito
+positiva. Neste artigo vamos ver o que mudou, por que isso é importante e como você pode começar a usar os novos
+recursos nos seus próprios projetos ainda hoje. O governo anunciou que o orçamento para o próximo ano vai incluir
+mais dinheiro para escolas e hospitais. Pesquisadores da universidade encontraram uma maneira de treinar grandes
+modelos com menos dados, o que pode tornar a tecnologia mais barata e acessível para todos. Ainda há muito
+trabalho a fazer, mas os resultados das primeiras experiências são animadores. Estamos contratando engenheiros
+que querem construir sistemas confiáveis e gostam de trabalhar com pessoas do mundo inteiro. Por favor, leia a
+história completa no nosso site e compartilhe suas opiniões conosco nos comentários. O que você acha disso? É a
+primeira vez que a empresa tem lucro desde a sua fundação, e as ações subiram.`,
+
+	"pl": `Nowa wersja języka wprowadza typy generyczne do biblioteki standardowej i poprawia wydajność
+odśmiecacza pamięci. Programiści długo czekali na to wydanie, a reakcja społeczności była bardzo pozytywna.
+W tym artykule przyjrzymy się temu, co się zmieniło, dlaczego to ważne i jak możesz zacząć korzystać z nowych
+funkcji we własnych projektach już dziś. Rząd ogłosił, że budżet na przyszły rok przewiduje więcej pieniędzy
+na szkoły i szpitale. Naukowcy z uniwersytetu znaleźli sposób na trenowanie dużych modeli przy użyciu mniejszej
+ilości danych, co może sprawić, że technologia będzie tańsza i bardziej dostępna dla wszystkich. Wciąż jest
+wiele do zrobienia, ale wyniki pierwszych eksperymentów są zachęcające. Zatrudniamy inżynierów, którzy chcą
+budować niezawodne systemy i lubią pracować z ludźmi z całego świata. Przeczytaj całą historię na naszej stronie
+i podziel się z nami swoimi przemyśleniami w komentarzach. Co o tym sądzisz? To pierwszy raz, kiedy firma
+osiągnęła zysk od czasu jej założenia, a akcje wzrosły.`,
+
+	"nl": `De nieuwe versie van de taal brengt generics naar de standaardbibliotheek en verbetert de prestaties
+van de garbage collector. Ontwikkelaars hebben lang op deze release gewacht en de reactie van de gemeenschap
+was erg positief. In dit artikel bekijken we wat er veranderd is, waarom het belangrijk is en hoe je de nieuwe
+functies vandaag al in je eigen projecten kunt gebruiken. De regering heeft aangekondigd dat de begroting voor
+volgend jaar meer geld voor scholen en ziekenhuizen bevat. Onderzoekers van de universiteit hebben een manier
+gevonden om grote modellen met minder gegevens te trainen, wat de technologie goedkoper en toegankelijker voor
+iedereen zou kunnen maken. Er is nog veel werk te doen, maar de resultaten van de eerste experimenten zijn
+bemoedigend. Wij zoeken ingenieurs die betrouwbare systemen willen bouwen en graag met mensen van over de hele
+wereld samenwerken. Lees het volledige verhaal op onze website en deel je gedachten met ons in de reacties.
+Wat vind jij ervan? Het is de eerste keer dat het bedrijf sinds de oprichting winst heeft gemaakt, en de
+aandelen zijn gestegen.`,
+}
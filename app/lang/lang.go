@@ -0,0 +1,166 @@
+// Package lang detects language of a text offline. Script is detected first, texts in Latin and Cyrillic
+// are compared with trigram profiles of the languages by the out-of-place distance (Cavnar and Trenkle).
+package lang
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	profileSize = 300 // trigrams kept in each profile
+	minLetters  = 20  // shorter texts are not detected
+)
+
+var reTags = regexp.MustCompile(`<[^>]*>`)
+
+// profiles are trigram ranks by language, built from corpus
+var profiles = map[string]map[string]int{}
+
+// languages with the same script, compared by profiles. The first script wins if text has as many letters of another.
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	langs  []string
+}{
+	{unicode.Latin, []string{"en", "de", "fr", "es", "it", "pt", "pl", "nl"}},
+	{unicode.Cyrillic, []string{"uk", "ru"}},
+}
+
+// languages detected by script alone, checked in this order
+var scriptOnly = []struct {
+	script *unicode.RangeTable
+	lang   string
+}{
+	{unicode.Hiragana, "ja"}, {unicode.Katakana, "ja"}, {unicode.Hangul, "ko"}, {unicode.Han, "zh"},
+	{unicode.Arabic, "ar"}, {unicode.Hebrew, "he"}, {unicode.Greek, "el"}, {unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+func init() {
+	for code, text := range corpus {
+		profiles[code] = rank(trigrams(text))
+	}
+}
+
+// Detect returns ISO 639-1 code of the text language, empty if the text is too short or the language unknown.
+// Html tags and entities are stripped.
+func Detect(text string) string {
+	text = html.UnescapeString(reTags.ReplaceAllString(text, " "))
+	counts := map[*unicode.RangeTable]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, sl := range scriptLanguages {
+			if unicode.Is(sl.script, r) {
+				counts[sl.script]++
+			}
+		}
+		for _, so := range scriptOnly {
+			if unicode.Is(so.script, r) {
+				counts[so.script]++
+			}
+		}
+	}
+	if letters < minLetters {
+		return ""
+	}
+
+	// kana is mixed with han in japanese, so any noticeable kana wins
+	for _, so := range scriptOnly {
+		if n := counts[so.script]; n*4 >= letters || (so.lang == "ja" && n*10 >= letters) {
+			return so.lang
+		}
+	}
+
+	var langs []string
+	most := 0
+	for _, sl := range scriptLanguages {
+		if n := counts[sl.script]; n*2 >= letters && n > most {
+			langs, most = sl.langs, n
+		}
+	}
+	if len(langs) == 0 {
+		return ""
+	}
+
+	doc := rank(trigrams(text))
+	best, bestDist := "", -1
+	for _, code := range langs {
+		if d := distance(doc, profiles[code]); bestDist < 0 || d < bestDist {
+			best, bestDist = code, d
+		}
+	}
+	return best
+}
+
+// Supported checks if the language can be detected
+func Supported(code string) bool {
+	if _, ok := profiles[code]; ok {
+		return true
+	}
+	for _, so := range scriptOnly {
+		if so.lang == code {
+			return true
+		}
+	}
+	return false
+}
+
+// trigrams counts trigrams of lowercased words padded with spaces, non-letters are separators
+func trigrams(text string) map[string]int {
+	res := map[string]int{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) })
+	for _, w := range words {
+		rs := []rune(" " + w + " ")
+		for i := 0; i+3 <= len(rs); i++ {
+			res[string(rs[i:i+3])]++
+		}
+	}
+	return res
+}
+
+// rank returns ranks of up to profileSize most frequent trigrams
+func rank(counts map[string]int) map[string]int {
+	grams := make([]string, 0, len(counts))
+	for g := range counts {
+		grams = append(grams, g)
+	}
+	sort.Slice(grams, func(i, j int) bool {
+		if counts[grams[i]] != counts[grams[j]] {
+			return counts[grams[i]] > counts[grams[j]]
+		}
+		return grams[i] < grams[j]
+	})
+	if len(grams) > profileSize {
+		grams = grams[:profileSize]
+	}
+	res := make(map[string]int, len(grams))
+	for i, g := range grams {
+		res[g] = i
+	}
+	return res
+}
+
+// distance is the out-of-place measure, sum of rank differences with max penalty for missing trigrams
+func distance(doc, profile map[string]int) int {
+	res := 0
+	for g, r := range doc {
+		pr, ok := profile[g]
+		if !ok {
+			res += profileSize
+			continue
+		}
+		if r > pr {
+			res += r - pr
+		} else {
+			res += pr - r
+		}
+	}
+	return res
+}
@@ -0,0 +1,65 @@
+package lang
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tbl := []struct {
+		text, want string
+	}{
+		{"The city council approved a plan to build new bike lanes and parks along the river next year", "en"},
+		{"Die Stadt hat beschlossen, im nächsten Jahr neue Radwege und Parks am Fluss zu bauen", "de"},
+		{"La ville a décidé de construire de nouvelles pistes cyclables et des parcs le long de la rivière", "fr"},
+		{"La ciudad decidió construir nuevos carriles para bicicletas y parques a lo largo del río el próximo año", "es"},
+		{"La città ha deciso di costruire nuove piste ciclabili e parchi lungo il fiume il prossimo anno", "it"},
+		{"A cidade decidiu construir novas ciclovias e parques ao longo do rio no próximo ano", "pt"},
+		{"Miasto zdecydowało się zbudować nowe ścieżki rowerowe i parki wzdłuż rzeki w przyszłym roku", "pl"},
+		{"De stad heeft besloten om volgend jaar nieuwe fietspaden en parken langs de rivier aan te leggen", "nl"},
+		{"Міська рада ухвалила план будівництва нових велодоріжок і парків уздовж річки наступного року", "uk"},
+		{"Городской совет одобрил план строительства новых велодорожек и парков вдоль реки в следующем году", "ru"},
+		{"市議会は来年、川沿いに新しい自転車道と公園を建設する計画を承認しました", "ja"},
+		{"시의회는 내년에 강을 따라 새로운 자전거 도로와 공원을 건설하는 계획을 승인했습니다", "ko"},
+		{"市议会批准了明年沿河修建新自行车道和公园的计划，工程将分三个阶段进行", "zh"},
+		{"وافق مجلس المدينة على خطة لبناء مسارات جديدة للدراجات وحدائق على طول النهر", "ar"},
+		{"מועצת העיר אישרה תוכנית לבניית שבילי אופניים ופארקים חדשים לאורך הנהר", "he"},
+		{"Το δημοτικό συμβούλιο ενέκρινε σχέδιο για νέους ποδηλατόδρομους και πάρκα κατά μήκος του ποταμού", "el"},
+		{"สภาเมืองอนุมัติแผนการสร้างทางจักรยานและสวนสาธารณะใหม่ริมแม่น้ำในปีหน้า", "th"},
+		{"नगर परिषद ने अगले साल नदी के किनारे नए साइकिल मार्ग और पार्क बनाने की योजना को मंजूरी दी", "hi"},
+		{"<p>The city council <b>approved</b> a plan &amp; new bike lanes along the river</p>", "en"},
+		{"Go 1.23 released", ""},
+		{"", ""},
+		{"<p>1234 5678 &amp; 9012 3456 7890 1234 5678</p>", ""},
+	}
+	for _, tt := range tbl {
+		if got := Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectScriptTie(t *testing.T) {
+	text := "Bike lanes along rivers Велодорожки вдоль реки" // 20 latin and 20 cyrillic letters, latin goes first
+	want := Detect(text)
+	if want == "" || !slices.Contains(scriptLanguages[0].langs, want) {
+		t.Fatalf("Detect(%q) = %q, want latin language", text, want)
+	}
+	for i := 0; i < 50; i++ {
+		if got := Detect(text); got != want {
+			t.Fatalf("Detect(%q) = %q, then %q", text, want, got)
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	for _, code := range []string{"en", "de", "fr", "es", "it", "pt", "pl", "nl", "uk", "ru", "ja", "ko", "zh", "ar", "he",
+		"el", "th", "hi"} {
+		if !Supported(code) {
+			t.Errorf("%s not supported", code)
+		}
+	}
+	if Supported("xx") || Supported("") {
+		t.Error("unknown language supported")
+	}
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
 	"path"
+	"sort"
 	"time"
+	_ "time/tzdata" // delivery windows time zones, in case the system has no zoneinfo
 
 	log "github.com/go-pkgz/lgr"
 	"github.com/jessevdk/go-flags"
@@ -13,7 +16,9 @@ import (
 
 	"github.com/umputun/feed-master/app/api"
 	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/expr"
 	"github.com/umputun/feed-master/app/proc"
+	"github.com/umputun/feed-master/app/pubsub"
 )
 
 type options struct {
@@ -30,6 +35,11 @@ type options struct {
 
 	TelegramTimeout time.Duration `long:"telegram_timeout" env:"TELEGRAM_TIMEOUT" default:"1m" description:"telegram timeout"`
 
+	Seed bool `long:"seed" description:"fetch all sources and save items without sending, then exit"`
+
+	Expr     string `long:"expr" description:"print stored items matching the filter expression and exit"`
+	ExprFeed string `long:"expr-feed" description:"limit --expr to the feed, all feeds if empty"`
+
 	Dbg bool `long:"dbg" env:"DEBUG" description:"debug mode"`
 }
 
@@ -58,6 +68,19 @@ func main() {
 	}
 	procStore := &proc.BoltDB{DB: db}
 
+	if opts.Expr != "" {
+		if err := matchExpr(conf, procStore, opts.Expr, opts.ExprFeed); err != nil {
+			log.Fatalf("[ERROR] can't match expression, %v", err)
+		}
+		return
+	}
+
+	if indexed, err := procStore.BuildIndex(); err != nil {
+		log.Printf("[WARN] can't build search index, %v", err)
+	} else if indexed > 0 {
+		log.Printf("[INFO] search index built, %d items", indexed)
+	}
+
 	telegramNotif, err := proc.NewTelegramClient(
 		opts.TelegramToken,
 		opts.TelegramServer,
@@ -68,7 +91,19 @@ func main() {
 		log.Fatalf("[ERROR] failed to initialize telegram client %s, %v", opts.TelegramToken, err)
 	}
 
-	p := &proc.Processor{Conf: conf, Store: procStore, TelegramNotif: telegramNotif}
+	events := pubsub.NewBroker(64)
+
+	limiter := proc.NewRateLimiter(conf.System.TelegramRate.Global, conf.System.TelegramRate.PerChat)
+	p := &proc.Processor{Conf: conf, Store: procStore, TelegramNotif: telegramNotif, Events: events, Limiter: limiter}
+	if opts.Seed {
+		p.Seed(context.Background())
+		return
+	}
+	if telegramNotif.Bot != nil {
+		proc.Commands{Processor: p, Bot: telegramNotif.Bot}.Register()
+	}
+	telegramNotif.Start()
+	go p.Deliver(context.Background())
 	go func() {
 		if err := p.Do(context.Background()); err != nil {
 			log.Printf("[ERROR] processor failed: %v", err)
@@ -79,10 +114,51 @@ func main() {
 		Version: revision,
 		Conf:    *p.Conf,
 		Store:   procStore,
+		Events:  events,
+		Limiter: limiter,
 	}
 	server.Run(context.Background(), opts.Port)
 }
 
+// matchExpr prints all stored items of the feed (or all feeds) matching the expression
+func matchExpr(conf *config.Conf, store *proc.BoltDB, src, fmFeed string) error {
+	prog, err := expr.Compile(src)
+	if err != nil {
+		return err
+	}
+
+	feeds := []string{fmFeed}
+	if fmFeed == "" {
+		feeds = feeds[:0]
+		for name := range conf.Feeds {
+			feeds = append(feeds, name)
+		}
+		sort.Strings(feeds)
+	}
+
+	matched := 0
+	for _, name := range feeds {
+		items, err := store.Load(name, math.MaxInt, false)
+		if err != nil {
+			log.Printf("[WARN] can't load %s, %v", name, err)
+			continue
+		}
+		sourceURLs := map[string]string{}
+		for _, s := range conf.Feeds[name].Sources {
+			sourceURLs[s.Name] = s.URL
+		}
+		for _, item := range items { //nolint
+			if !prog.Match(expr.Env{Item: item, Feed: name, Source: item.Source, SourceURL: sourceURLs[item.Source]}) {
+				continue
+			}
+			matched++
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", name, item.DT.Format(time.RFC3339), item.Source, item.Title, item.Link)
+		}
+	}
+	fmt.Printf("matched %d items\n", matched)
+	return nil
+}
+
 func makeBoltDB(dbFile string) (*bolt.DB, error) {
 	log.Printf("[INFO] bolt (persistent) store, %s", dbFile)
 
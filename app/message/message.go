@@ -0,0 +1,290 @@
+// Package message renders telegram messages of feed items with text/template.
+// Templates produce telegram HTML, item fields must be escaped with "escape" helper.
+//
+//	<a href="{{escape .Link}}"><b>{{escape .Title}}</b></a> {{reltime .DT}}, {{source .}}
+//	{{plain .Description | truncate 300 | escape}}
+//	{{tags .Tags}}
+package message
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf16"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// telegram limits, in UTF-16 code units
+const (
+	MaxLength        = 4096 // of a message
+	MaxCaptionLength = 1024 // of a photo caption
+)
+
+// Default is the template used for feeds without one
+const Default = `<a href="{{escape .Link}}"><b>{{escape .Title}}</b></a>{{arxiv .}}{{with .Tags}}
+
+{{tags .}}{{end}}`
+
+// Data is passed to the template, item fields are available directly, like {{.Title}}
+type Data struct {
+	feed.Item
+	FeedName    string // name of the feed in config
+	SourceTitle string // title of the source rss channel
+	SourceLink  string // link of the source rss channel
+}
+
+// Template is a compiled message template
+type Template struct {
+	tmpl *template.Template
+}
+
+var funcs = template.FuncMap{
+	"escape":   Escape,
+	"truncate": truncate,
+	"plain":    plain,
+	"source":   source,
+	"tags":     hashtags,
+	"reltime":  reltime,
+	"domain":   domain,
+	"arxiv":    arxivHTML,
+}
+
+// Compile parses the template and checks it by rendering a sample item, empty text means Default
+func Compile(text string) (*Template, error) {
+	if text == "" {
+		text = Default
+	}
+	tmpl, err := template.New("message").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	res := &Template{tmpl: tmpl}
+	if _, err = res.Render(sample); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// MustCompile is like Compile but panics on error, for templates known to be valid
+func MustCompile(text string) *Template {
+	res, err := Compile(text)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// Render makes message from data, messages longer than MaxLength are cut with tags closed
+func (t *Template) Render(data Data) (string, error) {
+	return t.render(data, MaxLength)
+}
+
+// RenderCaption makes photo caption from data, cut to MaxCaptionLength
+func (t *Template) RenderCaption(data Data) (string, error) {
+	return t.render(data, MaxCaptionLength)
+}
+
+func (t *Template) render(data Data, maxLen int) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return Fit(strings.TrimSpace(buf.String()), maxLen), nil
+}
+
+// sample is data used to check templates on compile
+var sample = Data{
+	Item: feed.Item{
+		Title: "Sample <title> & more", Link: "https://example.com/a?b=1&c=2", Description: "<p>description</p>",
+		GUID: "guid", Author: "author", Categories: []string{"go"}, Tags: []string{"go"}, Source: "source",
+		DT: time.Now(), Lang: "en", Score: 1, Enclosure: feed.Enclosure{URL: "https://example.com/a.mp3"},
+		Arxiv: &feed.Arxiv{ID: "2401.00001", Version: "v1", Announce: "new", Authors: []string{"A. Author"},
+			Primary: "cs.CV", Abstract: "abstract"},
+	},
+	FeedName:    "feed",
+	SourceTitle: "Source",
+	SourceLink:  "https://example.com",
+}
+
+// Escape makes text safe for telegram HTML, in text and attribute values
+func Escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+var reTags = regexp.MustCompile(`<[^>]*>`)
+
+// plain strips html tags and unescapes entities, spaces are collapsed
+func plain(s any) string {
+	text := html.UnescapeString(reTags.ReplaceAllString(fmt.Sprint(s), " "))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// truncate cuts text to max characters on a word boundary, used as {{truncate 100 .Title}}
+func truncate(maxLen int, text string) string {
+	rs := []rune(text)
+	if len(rs) <= maxLen {
+		return text
+	}
+	cut := rs[:max(maxLen, 0)]
+	for i := len(cut) - 1; i > len(cut)/2; i-- {
+		if cut[i] == ' ' {
+			cut = cut[:i]
+			break
+		}
+	}
+	return strings.TrimRight(string(cut), " ,.;:") + "…"
+}
+
+// source returns name of the item source, or title of the source channel if not set
+func source(d Data) string {
+	if d.Source != "" {
+		return d.Source
+	}
+	return d.SourceTitle
+}
+
+// hashtags makes space-separated telegram hashtags from tags
+func hashtags(tags []string) string {
+	res := make([]string, 0, len(tags))
+	for _, t := range tags {
+		res = append(res, "#"+t)
+	}
+	return strings.Join(res, " ")
+}
+
+// reltime returns time relative to now, like "3 hours ago"
+func reltime(t time.Time) string {
+	return humanize.Time(t)
+}
+
+func domain(link string) string {
+	if i := strings.Index(link, "://"); i >= 0 {
+		link = link[i+3:]
+	}
+	if i := strings.IndexAny(link, "/?#"); i >= 0 {
+		link = link[:i]
+	}
+	if i := strings.LastIndex(link, ":"); i >= 0 {
+		link = link[:i]
+	}
+	return strings.TrimPrefix(strings.ToLower(link), "www.")
+}
+
+const (
+	arxivMaxAuthors  = 5
+	arxivMaxAbstract = 500 // characters of abstract excerpt
+)
+
+// arxivHTML makes authors, abstract excerpt and pdf link part of the message, empty for non-arXiv items
+func arxivHTML(d Data) string {
+	if d.Arxiv == nil {
+		return ""
+	}
+	a := d.Arxiv
+	var sb strings.Builder
+	if len(a.Authors) > 0 {
+		authors := strings.Join(a.Authors, ", ")
+		if len(a.Authors) > arxivMaxAuthors {
+			authors = strings.Join(a.Authors[:arxivMaxAuthors], ", ") + " et al."
+		}
+		sb.WriteString("\n<i>" + Escape(authors) + "</i>")
+	}
+	if a.Abstract != "" {
+		sb.WriteString("\n\n" + Escape(truncate(arxivMaxAbstract, a.Abstract)))
+	}
+	fmt.Fprintf(&sb, "\n\n<a href=\"%s\">PDF</a>", Escape(a.PDFLink()))
+	if a.Primary != "" {
+		sb.WriteString(" · " + Escape(a.Primary))
+	}
+	return sb.String()
+}
+
+var reTag = regexp.MustCompile(`<(/?)([a-zA-Z\-]+)[^>]*>`)
+
+// Append adds the html note to the message as a separate paragraph, the message is cut to keep it within maxLen.
+// The note is cut too if it doesn't fit alone.
+func Append(msg, note string, maxLen int) string {
+	const sep = "\n\n"
+	room := maxLen - Length(sep+note)
+	if room <= 0 {
+		return Fit(note, maxLen)
+	}
+	return Fit(msg, room) + sep + note
+}
+
+// Length returns length of the text as telegram counts it, in UTF-16 code units
+func Length(s string) int {
+	n := 0
+	for _, r := range s {
+		n += max(utf16.RuneLen(r), 1)
+	}
+	return n
+}
+
+// prefix returns the longest prefix of the text up to maxLen UTF-16 code units
+func prefix(s string, maxLen int) string {
+	n := 0
+	for i, r := range s {
+		if n += max(utf16.RuneLen(r), 1); n > maxLen {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// Fit cuts html message to maxLen UTF-16 code units, not inside a tag or entity, and closes tags left open
+func Fit(msg string, maxLen int) string {
+	if Length(msg) <= maxLen {
+		return msg
+	}
+	const ellipsis = "…"
+	if maxLen < Length(ellipsis) {
+		return ""
+	}
+	cut := prefix(msg, maxLen-Length(ellipsis))
+
+	// make room for closing tags, the longest is </blockquote>
+	for {
+		if i := strings.LastIndexByte(cut, '<'); i >= 0 && !strings.Contains(cut[i:], ">") {
+			cut = cut[:i]
+		}
+		if i := strings.LastIndexByte(cut, '&'); i >= 0 && !strings.Contains(cut[i:], ";") {
+			cut = cut[:i]
+		}
+		closing := closeTags(cut)
+		if Length(cut)+Length(ellipsis+closing) <= maxLen {
+			return cut + ellipsis + closing
+		}
+		cut = prefix(cut, Length(cut)-Length(closing))
+	}
+}
+
+// closeTags returns closing tags for tags left open in html
+func closeTags(s string) string {
+	var open []string
+	for _, m := range reTag.FindAllStringSubmatch(s, -1) {
+		name := strings.ToLower(m[2])
+		if m[1] == "" {
+			open = append(open, name)
+			continue
+		}
+		for i := len(open) - 1; i >= 0; i-- {
+			if open[i] == name {
+				open = open[:i]
+				break
+			}
+		}
+	}
+	var sb strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString("</" + open[i] + ">")
+	}
+	return sb.String()
+}
@@ -0,0 +1,189 @@
+package message
+
+import (
+	"flag"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestRenderGolden(t *testing.T) {
+	item := feed.Item{
+		Title:       `Go 1.23 <released> & "iterators"`,
+		Link:        "https://www.example.com/go?a=1&b=2",
+		Description: "<p>Range over <b>function</b> iterators &amp; telemetry, with many other changes to the toolchain.</p>",
+		GUID:        "guid-1",
+		Author:      "Gopher",
+		DT:          time.Date(2024, 8, 13, 16, 0, 0, 0, time.UTC),
+		Tags:        []string{"go", "release"},
+		Source:      "go-blog",
+	}
+	arxiv := item
+	arxiv.Tags = nil
+	arxiv.Arxiv = &feed.Arxiv{ID: "2401.12345", Version: "v2", Primary: "cs.CV",
+		Authors:  []string{"A. One", "B. Two", "C. Three", "D. Four", "E. Five", "F. Six"},
+		Abstract: "We study <things> & stuff. " + strings.Repeat("Long abstract text. ", 40)}
+	long := item
+	long.Description = template.HTML("<p>" + strings.Repeat("Lorem ipsum dolor sit amet 😀, ", 200) + "</p>")
+
+	tbl := []struct {
+		name    string
+		tmpl    string
+		data    Data
+		caption bool
+	}{
+		{name: "default", data: Data{Item: item}},
+		{name: "default_arxiv", data: Data{Item: arxiv}},
+		{name: "custom", data: Data{Item: item, FeedName: "news", SourceTitle: "Go Blog"},
+			tmpl: `<a href="{{escape .Link}}"><b>{{escape .Title}}</b></a> {{domain .Link}}, {{source .}}
+{{plain .Description | truncate 40 | escape}}
+{{tags .Tags}} {{.FeedName}}`},
+		{name: "custom_source_title", data: Data{Item: feed.Item{Title: "t", Link: "https://example.com"}, SourceTitle: "Go Blog"},
+			tmpl: `{{escape .Title}} - {{source .}}`},
+		{name: "truncated", data: Data{Item: long},
+			tmpl: `<b>{{escape .Title}}</b>
+<blockquote><i>{{plain .Description | escape}}</i></blockquote>`},
+		{name: "truncated_caption", data: Data{Item: long}, caption: true,
+			tmpl: `<b>{{escape .Title}}</b>
+<blockquote><i>{{plain .Description | escape}}</i></blockquote>`},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Compile(tt.tmpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			render, maxLen := tmpl.Render, MaxLength
+			if tt.caption {
+				render, maxLen = tmpl.RenderCaption, MaxCaptionLength
+			}
+			got, err := render(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l := Length(got); l > maxLen {
+				t.Errorf("length %d is over %d", l, maxLen)
+			}
+
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err = os.WriteFile(golden, []byte(got), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden) //nolint:gosec // test data
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, tmpl := range []string{`{{.Unknown}}`, `{{escape .Title`, `{{nofunc .Title}}`, `{{truncate "x" .Title}}`} {
+		if _, err := Compile(tmpl); err == nil {
+			t.Errorf("no error for %q", tmpl)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	tbl := []struct {
+		name   string
+		msg    string
+		maxLen int
+		want   string
+	}{
+		{name: "fits", msg: "<b>short</b>", maxLen: 20, want: "<b>short</b>"},
+		{name: "plain cut", msg: "abcdefghij", maxLen: 5, want: "abcd…"},
+		{name: "close tags", msg: "<b>bold <i>italic text here</i></b>", maxLen: 20, want: "<b>bold <i>…</i></b>"},
+		{name: "close nested tags", msg: "<b>bold <i>italic text here and more</i></b>", maxLen: 28, want: "<b>bold <i>italic t…</i></b>"},
+		{name: "not inside tag", msg: `text <a href="https://example.com/long">link</a>`, maxLen: 20, want: "text …"},
+		{name: "not inside entity", msg: "abc &amp; def", maxLen: 7, want: "abc …"},
+		{name: "emoji count as two", msg: "😀😀😀😀😀", maxLen: 5, want: "😀😀…"},
+		{name: "cyrillic count as one", msg: "привет мир", maxLen: 5, want: "прив…"},
+		{name: "ellipsis only", msg: "abc", maxLen: 1, want: "…"},
+		{name: "zero", msg: "abc", maxLen: 0, want: ""},
+		{name: "negative", msg: "abc", maxLen: -5, want: ""},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Fit(tt.msg, tt.maxLen)
+			if got != tt.want {
+				t.Errorf("Fit(%q, %d) = %q, want %q", tt.msg, tt.maxLen, got, tt.want)
+			}
+			if Length(got) > max(tt.maxLen, 0) {
+				t.Errorf("length %d over %d", Length(got), tt.maxLen)
+			}
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	tbl := []struct {
+		msg, note string
+		maxLen    int
+		want      string
+	}{
+		{"<b>title</b>", "<i>removed</i>", 100, "<b>title</b>\n\n<i>removed</i>"},
+		{"<b>long title</b>", "<i>note</i>", 24, "<b>lon…</b>\n\n<i>note</i>"},
+		{"<b>title</b>", "<i>very long note</i>", 10, "<i>ve…</i>"},
+		{"<b>title</b>", "<i>note</i>", 0, ""},
+	}
+	for _, tt := range tbl {
+		if got := Append(tt.msg, tt.note, tt.maxLen); got != tt.want {
+			t.Errorf("Append(%q, %q, %d) = %q, want %q", tt.msg, tt.note, tt.maxLen, got, tt.want)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	tbl := []struct {
+		s    string
+		want int
+	}{{"", 0}, {"abc", 3}, {"привет", 6}, {"日本", 2}, {"😀", 2}, {"a😀b", 4}, {"𝔸", 2}}
+	for _, tt := range tbl {
+		if got := Length(tt.s); got != tt.want {
+			t.Errorf("Length(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tbl := []struct {
+		text   string
+		maxLen int
+		want   string
+	}{
+		{"short text", 20, "short text"},
+		{"one two three four", 13, "one two…"},
+		{"one two three, four", 14, "one two three…"},
+		{"averyveryverylongword and more", 10, "averyveryv…"},
+		{"привет мир как дела", 14, "привет мир…"},
+		{"日本語のテキスト 日本語のテキスト", 12, "日本語のテキスト…"},
+		{"emoji 🎉🎉🎉 and more", 10, "emoji 🎉🎉🎉…"},
+		{"any text", 0, "…"},
+		{"any text", -1, "…"},
+	}
+	for _, tt := range tbl {
+		if got := truncate(tt.maxLen, tt.text); got != tt.want {
+			t.Errorf("truncate(%d, %q) = %q, want %q", tt.maxLen, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if got := Escape(`<a href="x">Tom & Jerry</a>`); got != "&lt;a href=&quot;x&quot;&gt;Tom &amp; Jerry&lt;/a&gt;" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package proc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPublicOnly(t *testing.T) {
+	tbl := []struct {
+		address string
+		ok      bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[fe80::1]:80", false},
+		{"224.0.0.1:80", false},
+		{"bad", false},
+	}
+	for _, tt := range tbl {
+		if err := publicOnly("tcp", tt.address, nil); (err == nil) != tt.ok {
+			t.Errorf("publicOnly(%s) = %v, want ok %v", tt.address, err, tt.ok)
+		}
+	}
+}
+
+func TestPageClientRejectsInternal(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodHead, ts.URL, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := pageClient.Do(req); err == nil {
+		_ = resp.Body.Close()
+		t.Errorf("no error for %s", ts.URL)
+	}
+}
@@ -0,0 +1,426 @@
+package proc
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/message"
+)
+
+// maxLast is the max number of items shown by /last
+const maxLast = 20
+
+// Commands handles bot commands in groups of feeds. Commands apply to feeds sent to the chat, to the feed sent
+// to the topic in forums. Changes are allowed to chat admins only and saved as runtime overrides of feeds.
+// Personal subscriptions are managed in private chat with the bot.
+type Commands struct {
+	Processor *Processor
+	Bot       *tb.Bot
+}
+
+// Register adds handlers of commands to the bot
+func (cmd Commands) Register() {
+	handlers := map[string]func(tb.Context, []string) (string, error){
+		"/feeds":   cmd.feeds,
+		"/sources": cmd.sources,
+		"/last":    cmd.last,
+		"/status":  cmd.status,
+		"/add":     cmd.admin(cmd.add),
+		"/remove":  cmd.admin(cmd.remove),
+		"/mute":    cmd.admin(cmd.mute),
+		"/filter":  cmd.admin(cmd.filter),
+
+		// personal subscriptions in private chat
+		"/start":       cmd.private(cmd.start),
+		"/subscribe":   cmd.private(cmd.subscribe),
+		"/unsubscribe": cmd.private(cmd.unsubscribe),
+		"/list":        cmd.private(cmd.list),
+	}
+	for name, h := range handlers {
+		name, h := name, h
+		cmd.Bot.Handle(name, func(c tb.Context) error {
+			reply, err := h(c, c.Args())
+			if err != nil {
+				log.Printf("[INFO] command %s %v in %d failed, %v", name, c.Args(), c.Chat().ID, err)
+				reply = message.Escape(err.Error())
+			}
+			return cmd.reply(c, reply)
+		})
+	}
+}
+
+// reply sends HTML reply to the topic of the command
+func (cmd Commands) reply(c tb.Context, text string) error {
+	opts := &tb.SendOptions{ParseMode: tb.ModeHTML, DisableWebPagePreview: true}
+	if msg := c.Message(); msg != nil && msg.TopicMessage {
+		opts.ThreadID = msg.ThreadID
+	}
+	return c.Send(text, opts)
+}
+
+// admin allows the command to chat admins only
+func (cmd Commands) admin(h func(tb.Context, []string) (string, error)) func(tb.Context, []string) (string, error) {
+	return func(c tb.Context, args []string) (string, error) {
+		if c.Sender() == nil {
+			return "", errors.New("unknown sender")
+		}
+		admins, err := cmd.Bot.AdminsOf(c.Chat())
+		if err != nil {
+			return "", errors.Wrap(err, "can't get chat admins")
+		}
+		for _, a := range admins {
+			if a.User != nil && a.User.ID == c.Sender().ID {
+				return h(c, args)
+			}
+		}
+		return "", errors.New("allowed to chat admins only")
+	}
+}
+
+// chatFeeds returns sorted names of feeds sent to the chat of the command. In a topic, feeds sent to
+// the topic if any.
+func (cmd Commands) chatFeeds(c tb.Context) []string {
+	chat := c.Chat()
+	thread := 0
+	if msg := c.Message(); msg != nil && msg.TopicMessage {
+		thread = msg.ThreadID
+	}
+	var all, topic []string
+	for name, fm := range cmd.Processor.Conf.Feeds { //nolint
+		for _, dst := range fm.Destinations("") {
+			if !sameChat(dst.Chat, chat) {
+				continue
+			}
+			if !slices.Contains(all, name) {
+				all = append(all, name)
+			}
+			if dst.Topic == thread && !slices.Contains(topic, name) {
+				topic = append(topic, name)
+			}
+		}
+		// sources can be sent to their own topics
+		for _, src := range fm.Sources {
+			if src.TelegramTopicID != 0 && src.TelegramTopicID == thread && sameChat(fm.TelegramGroupID, chat) &&
+				!slices.Contains(topic, name) {
+				topic = append(topic, name)
+			}
+		}
+	}
+	res := all
+	if len(topic) > 0 {
+		res = topic
+	}
+	sort.Strings(res)
+	return res
+}
+
+// sameChat checks if destination chat, id or @name, is the chat
+func sameChat(dst string, chat *tb.Chat) bool {
+	if dst == strconv.FormatInt(chat.ID, 10) {
+		return true
+	}
+	return chat.Username != "" && strings.EqualFold(strings.TrimPrefix(dst, "@"), chat.Username)
+}
+
+// feed returns the only feed of the chat, error if none or several
+func (cmd Commands) feed(c tb.Context) (string, error) {
+	names := cmd.chatFeeds(c)
+	switch len(names) {
+	case 0:
+		return "", errors.New("no feeds sent to this chat")
+	case 1:
+		return names[0], nil
+	default:
+		return "", errors.Errorf("several feeds sent to this chat (%s), use the command in a topic of one of them",
+			strings.Join(names, ", "))
+	}
+}
+
+// sourceFeed finds the feed of the chat with the source, effective sources with runtime overrides
+func (cmd Commands) sourceFeed(c tb.Context, source string) (string, error) {
+	for _, name := range cmd.chatFeeds(c) {
+		ov := cmd.Processor.overrides(name)
+		for _, src := range ov.Sources(cmd.Processor.Conf.Feeds[name]) {
+			if src.Name == source {
+				return name, nil
+			}
+		}
+	}
+	return "", errors.Errorf("no source %q in feeds of this chat", source)
+}
+
+// /feeds - feeds sent to the chat
+func (cmd Commands) feeds(c tb.Context, _ []string) (string, error) {
+	names := cmd.chatFeeds(c)
+	if len(names) == 0 {
+		return "no feeds sent to this chat", nil
+	}
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		fm := cmd.Processor.Conf.Feeds[name]
+		sources := cmd.Processor.overrides(name).Sources(fm)
+		lines = append(lines, fmt.Sprintf("<b>%s</b> %s, %d sources", message.Escape(name), message.Escape(fm.Title),
+			len(sources)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// /sources - sources of the chat feeds, with runtime changes
+func (cmd Commands) sources(c tb.Context, _ []string) (string, error) {
+	names := cmd.chatFeeds(c)
+	if len(names) == 0 {
+		return "no feeds sent to this chat", nil
+	}
+	var lines []string
+	now := time.Now()
+	for _, name := range names {
+		fm := cmd.Processor.Conf.Feeds[name]
+		ov := cmd.Processor.overrides(name)
+		lines = append(lines, fmt.Sprintf("<b>%s</b>", message.Escape(name)))
+		for _, src := range ov.Sources(fm) {
+			line := fmt.Sprintf("• %s %s", message.Escape(src.Name), message.Escape(src.URL))
+			if slices.ContainsFunc(ov.Added, func(s config.Source) bool { return s.Name == src.Name }) {
+				line += ", added"
+			}
+			if until := ov.MutedUntil(src.Name, now); !until.IsZero() {
+				line += ", muted until " + until.Format("2006-01-02 15:04")
+			}
+			lines = append(lines, line)
+		}
+		for _, r := range ov.Removed {
+			lines = append(lines, fmt.Sprintf("• <s>%s</s> removed", message.Escape(r)))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// /last [n] - latest n non-junk items of the chat feeds, 5 by default
+func (cmd Commands) last(c tb.Context, args []string) (string, error) {
+	n := 5
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+			return "", errors.Errorf("bad number %q", args[0])
+		}
+	}
+	n = min(n, maxLast)
+
+	var items []feed.Item
+	for _, name := range cmd.chatFeeds(c) {
+		feedItems, _, err := cmd.Processor.Store.LoadPage(name, feed.Query{Limit: n, SkipJunk: true})
+		if err != nil {
+			log.Printf("[DEBUG] no items of %s, %v", name, err) // feed without items yet has no bucket
+			continue
+		}
+		items = append(items, feedItems...)
+	}
+	if len(items) == 0 {
+		return "no items", nil
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DT.After(items[j].DT) })
+	if len(items) > n {
+		items = items[:n]
+	}
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, fmt.Sprintf(`• <a href="%s">%s</a> <i>%s</i>`, message.Escape(item.Link),
+			message.Escape(item.Title), message.Escape(item.Source)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// /status - outbox and runtime overrides of the chat feeds
+func (cmd Commands) status(c tb.Context, _ []string) (string, error) {
+	names := cmd.chatFeeds(c)
+	if len(names) == 0 {
+		return "no feeds sent to this chat", nil
+	}
+	stats, err := cmd.Processor.Store.OutboxStats()
+	if err != nil {
+		return "", errors.Wrap(err, "can't get outbox stats")
+	}
+	var lines []string
+	now := time.Now()
+	for _, name := range names {
+		ov := cmd.Processor.overrides(name)
+		muted := 0
+		for src := range ov.Muted {
+			if !ov.MutedUntil(src, now).IsZero() {
+				muted++
+			}
+		}
+		lines = append(lines, fmt.Sprintf("<b>%s</b>: %d pending, %d failed, %d added, %d removed, %d muted sources, %d filters",
+			message.Escape(name), stats.Feeds[name], stats.Failed[name], len(ov.Added), len(ov.Removed), muted, len(ov.Filters)))
+	}
+	if lim := cmd.Processor.Limiter; lim != nil {
+		for chat, b := range lim.State().Chats {
+			if sameChat(chat, c.Chat()) {
+				lines = append(lines, fmt.Sprintf("rate limit: %.1f of %.0f messages left", b.Tokens, b.Size))
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// /add <url> [name] - adds the source to the feed, named by host of the url by default
+func (cmd Commands) add(c tb.Context, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("usage: /add <url> [name]")
+	}
+	name, err := cmd.feed(c)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(args[0])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.Errorf("bad url %q", args[0])
+	}
+	rss, err := feed.Parse(u.String())
+	if err != nil {
+		return "", errors.Wrapf(err, "can't read feed %s", u)
+	}
+	srcName := strings.TrimPrefix(u.Hostname(), "www.")
+	if len(args) > 1 {
+		srcName = args[1]
+	}
+
+	fm := cmd.Processor.Conf.Feeds[name]
+	err = cmd.Processor.Store.updateOverrides(name, func(o *Overrides) error {
+		for _, src := range o.Sources(fm) {
+			if src.Name == srcName || src.URL == u.String() {
+				return errors.Errorf("source %s %s already in %s", src.Name, src.URL, name)
+			}
+		}
+		o.Added = append(o.Added, config.Source{Name: srcName, URL: u.String()})
+		// source from config removed before is added back by name
+		o.Removed = slices.DeleteFunc(o.Removed, func(r string) bool { return r == srcName })
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	log.Printf("[INFO] source %s %s added to %s by %s", srcName, u, name, c.Sender().Username)
+	return fmt.Sprintf("added %s (%s, %d items) to %s", message.Escape(srcName), message.Escape(rss.Title), len(rss.ItemList),
+		message.Escape(name)), nil
+}
+
+// /remove <name> - removes the source from its feed
+func (cmd Commands) remove(c tb.Context, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("usage: /remove <source>")
+	}
+	name, err := cmd.sourceFeed(c, args[0])
+	if err != nil {
+		return "", err
+	}
+	err = cmd.Processor.Store.updateOverrides(name, func(o *Overrides) error {
+		added := len(o.Added)
+		o.Added = slices.DeleteFunc(o.Added, func(s config.Source) bool { return s.Name == args[0] })
+		if len(o.Added) == added {
+			o.Removed = append(o.Removed, args[0])
+		}
+		delete(o.Muted, args[0])
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	log.Printf("[INFO] source %s removed from %s by %s", args[0], name, c.Sender().Username)
+	return fmt.Sprintf("removed %s from %s", message.Escape(args[0]), message.Escape(name)), nil
+}
+
+// /mute <source> [duration] - stops sending items of the source, for 24h by default, 0 unmutes
+func (cmd Commands) mute(c tb.Context, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("usage: /mute <source> [duration, like 24h]")
+	}
+	dur := 24 * time.Hour
+	if len(args) > 1 {
+		var err error
+		if dur, err = time.ParseDuration(args[1]); err != nil || dur < 0 {
+			return "", errors.Errorf("bad duration %q", args[1])
+		}
+	}
+	name, err := cmd.sourceFeed(c, args[0])
+	if err != nil {
+		return "", err
+	}
+	until := time.Now().Add(dur)
+	err = cmd.Processor.Store.updateOverrides(name, func(o *Overrides) error {
+		if dur == 0 {
+			delete(o.Muted, args[0])
+			return nil
+		}
+		if o.Muted == nil {
+			o.Muted = map[string]time.Time{}
+		}
+		o.Muted[args[0]] = until
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if dur == 0 {
+		return fmt.Sprintf("unmuted %s", message.Escape(args[0])), nil
+	}
+	return fmt.Sprintf("muted %s until %s", message.Escape(args[0]), until.Format("2006-01-02 15:04")), nil
+}
+
+// /filter add <regex> | remove <n> | list - title filters of the feed, matched items are junk
+func (cmd Commands) filter(c tb.Context, args []string) (string, error) {
+	name, err := cmd.feed(c)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 || args[0] == "list" {
+		filters := cmd.Processor.overrides(name).Filters
+		if len(filters) == 0 {
+			return "no filters", nil
+		}
+		lines := make([]string, 0, len(filters))
+		for i, f := range filters {
+			lines = append(lines, fmt.Sprintf("%d. <code>%s</code>", i+1, message.Escape(f)))
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	switch {
+	case args[0] == "add" && len(args) > 1:
+		// regex can have spaces, split by telebot
+		re := strings.Join(args[1:], " ")
+		if _, err = regexp.Compile(re); err != nil {
+			return "", errors.Wrapf(err, "bad regex")
+		}
+		err = cmd.Processor.Store.updateOverrides(name, func(o *Overrides) error {
+			o.Filters = append(o.Filters, re)
+			return nil
+		})
+		return fmt.Sprintf("filter <code>%s</code> added to %s", message.Escape(re), message.Escape(name)), err
+	case args[0] == "remove" && len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "", errors.Errorf("bad filter number %q", args[1])
+		}
+		err = cmd.Processor.Store.updateOverrides(name, func(o *Overrides) error {
+			if n < 1 || n > len(o.Filters) {
+				return errors.Errorf("no filter %d", n)
+			}
+			o.Filters = slices.Delete(o.Filters, n-1, n)
+			return nil
+		})
+		return fmt.Sprintf("filter %d removed from %s", n, message.Escape(name)), err
+	}
+	return "", errors.New("usage: /filter add <regex> | remove <n> | list")
+}
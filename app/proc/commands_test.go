@@ -0,0 +1,164 @@
+package proc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// botAPIStub is telegram bot api server recording replies. Chat -100 has admin 1, admins of other chats
+// can't be loaded. It also serves a feed at /rss.
+type botAPIStub struct {
+	*httptest.Server
+	mu      sync.Mutex
+	replies []string
+}
+
+func newBotAPIStub(t *testing.T) *botAPIStub {
+	t.Helper()
+	s := &botAPIStub{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rss" {
+			_, _ = w.Write([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Extra feed</title>
+<item><title>item</title><link>https://example.com/1</link><guid>1</guid></item></channel></rss>`))
+			return
+		}
+		params := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Errorf("bad request %s, %v", r.URL.Path, err)
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getChatAdministrators") && params["chat_id"] == "-100":
+			_, _ = w.Write([]byte(`{"ok":true,"result":[{"status":"creator","user":{"id":1}}]}`))
+		case strings.HasSuffix(r.URL.Path, "/getChatAdministrators"):
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			s.mu.Lock()
+			s.replies = append(s.replies, fmt.Sprint(params["text"]))
+			s.mu.Unlock()
+			_, _ = fmt.Fprintf(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":%s}}}`, params["chat_id"])
+		default:
+			t.Errorf("unexpected call %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *botAPIStub) lastReply() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.replies) == 0 {
+		return ""
+	}
+	return s.replies[len(s.replies)-1]
+}
+
+func TestCommands(t *testing.T) {
+	stub := newBotAPIStub(t)
+	dbFile := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(dbFile, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &config.Conf{Feeds: map[string]config.Feed{
+		"news":  {TelegramGroupID: "-100", Sources: []config.Source{{Name: "s1", URL: "https://example.com/s1"}}},
+		"other": {TelegramGroupID: "-200", Sources: []config.Source{{Name: "s2", URL: "https://example.com/s2"}}},
+	}}
+	bot, err := tb.NewBot(tb.Settings{URL: stub.URL, Token: "token", Offline: true, Synchronous: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Commands{Processor: &Processor{Conf: conf, Store: &BoltDB{DB: db}}, Bot: bot}.Register()
+
+	tbl := []struct {
+		name  string
+		chat  int64
+		user  int64
+		text  string
+		reply string
+		exact bool
+	}{
+		{name: "not admin", chat: -100, user: 2, text: "/mute s1", reply: "allowed to chat admins only", exact: true},
+		{name: "admins unknown", chat: -200, user: 1, text: "/mute s2",
+			reply: "can't get chat admins: telegram: chat not found (400)", exact: true},
+		{name: "add", chat: -100, user: 1, text: "/add " + stub.URL + "/rss extra",
+			reply: "added extra (Extra feed, 1 items) to news", exact: true},
+		{name: "add duplicate", chat: -100, user: 1, text: "/add " + stub.URL + "/rss", reply: "already in news"},
+		{name: "add bad url", chat: -100, user: 1, text: "/add ftp://example.com", reply: "bad url"},
+		{name: "mute", chat: -100, user: 1, text: "/mute extra 2h", reply: "muted extra until"},
+		{name: "mute bad duration", chat: -100, user: 1, text: "/mute extra soon", reply: "bad duration"},
+		{name: "mute unknown", chat: -100, user: 1, text: "/mute s2", reply: "no source &quot;s2&quot; in feeds of this chat"},
+		{name: "filter add", chat: -100, user: 1, text: `/filter add ^sponsored \d+`,
+			reply: `filter <code>^sponsored \d+</code> added to news`, exact: true},
+		{name: "filter add bad", chat: -100, user: 1, text: "/filter add (", reply: "bad regex"},
+		{name: "filter add another", chat: -100, user: 1, text: "/filter add ads", reply: "added to news"},
+		{name: "filter remove", chat: -100, user: 1, text: "/filter remove 2", reply: "filter 2 removed from news", exact: true},
+		{name: "filter remove missing", chat: -100, user: 1, text: "/filter remove 5", reply: "no filter 5", exact: true},
+		{name: "filter list", chat: -100, user: 1, text: "/filter", reply: `1. <code>^sponsored \d+</code>`, exact: true},
+		{name: "remove", chat: -100, user: 1, text: "/remove s1", reply: "removed s1 from news", exact: true},
+		{name: "remove again", chat: -100, user: 1, text: "/remove s1", reply: "no source"},
+		{name: "status", chat: -100, user: 2, text: "/status",
+			reply: "<b>news</b>: 0 pending, 0 failed, 1 added, 1 removed, 1 muted sources, 1 filters", exact: true},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			bot.ProcessUpdate(tb.Update{Message: &tb.Message{Text: tt.text,
+				Chat: &tb.Chat{ID: tt.chat, Type: tb.ChatSuperGroup}, Sender: &tb.User{ID: tt.user, Username: "user"}}})
+			got := stub.lastReply()
+			if (tt.exact && got != tt.reply) || !strings.Contains(got, tt.reply) {
+				t.Errorf("reply %q, want %q", got, tt.reply)
+			}
+		})
+	}
+
+	// rejected commands changed nothing, changes survive restart
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = bolt.Open(dbFile, 0o600, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := &BoltDB{DB: db}
+
+	if ov, err := store.Overrides("other"); err != nil || len(ov.Muted) != 0 {
+		t.Errorf("overrides of other feed %+v, %v", ov, err)
+	}
+	ov, err := store.Overrides("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ov.Added) != 1 || ov.Added[0].Name != "extra" || ov.Added[0].URL != stub.URL+"/rss" {
+		t.Errorf("added %+v", ov.Added)
+	}
+	if len(ov.Removed) != 1 || ov.Removed[0] != "s1" {
+		t.Errorf("removed %+v", ov.Removed)
+	}
+	if _, ok := ov.Muted["extra"]; !ok || len(ov.Muted) != 1 {
+		t.Errorf("muted %+v", ov.Muted)
+	}
+	if len(ov.Filters) != 1 || ov.Filters[0] != `^sponsored \d+` {
+		t.Errorf("filters %+v", ov.Filters)
+	}
+	if reason := ov.junkReason(feed.Item{Title: "sponsored 42 times"}); reason != `bot filter ^sponsored \d+` {
+		t.Errorf("junk reason %q", reason)
+	}
+	if reason := ov.junkReason(feed.Item{Title: "not sponsored"}); reason != "" {
+		t.Errorf("junk reason %q", reason)
+	}
+	if srcs := ov.Sources(conf.Feeds["news"]); len(srcs) != 1 || srcs[0].Name != "extra" {
+		t.Errorf("effective sources %+v", srcs)
+	}
+}
@@ -0,0 +1,212 @@
+package proc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// dedupBucket keeps canonical links of items, nested bucket per feed
+const dedupBucket = "_dedup"
+
+// dedupKeysBucket is nested in feed's dedup bucket, maps item key to the canonical link recorded for it
+const dedupKeysBucket = "_keys"
+
+// dedupRecord is a value of dedup bucket, the first item seen with the canonical link
+type dedupRecord struct {
+	Key  string    `json:"key"`
+	Link string    `json:"link"`
+	TS   time.Time `json:"ts"`
+}
+
+// redirectors are link shorteners and trackers resolved to the target link before canonicalization
+var redirectors = map[string]bool{
+	"feedproxy.google.com": true, "feeds.feedburner.com": true, "t.co": true, "bit.ly": true, "buff.ly": true,
+	"ow.ly": true, "dlvr.it": true, "lnkd.in": true, "trib.al": true, "tinyurl.com": true, "goo.gl": true,
+}
+
+// trackingParams are dropped from query, in addition to utm_*
+var trackingParams = map[string]bool{
+	"ref": true, "ref_src": true, "ref_url": true, "fbclid": true, "gclid": true, "mc_cid": true,
+	"mc_eid": true, "yclid": true, "_hsenc": true, "_hsmi": true,
+}
+
+// DedupCheck marks item as a duplicate if another item with the same canonical link
+// was saved to the feed within the window, otherwise records the link. Junk items are neither
+// checked nor recorded, so they can't suppress later items.
+func DedupCheck(canonLink string, window time.Duration) SaveCheck {
+	return func(tx *bolt.Tx, fmFeed string, key []byte, item *feed.Item) error {
+		if canonLink == "" || item.Junk {
+			return nil
+		}
+		root, err := tx.CreateBucketIfNotExists([]byte(dedupBucket))
+		if err != nil {
+			return err
+		}
+		bucket, err := root.CreateBucketIfNotExists([]byte(fmFeed))
+		if err != nil {
+			return err
+		}
+
+		if v := bucket.Get([]byte(canonLink)); v != nil {
+			rec := dedupRecord{}
+			if err = json.Unmarshal(v, &rec); err != nil {
+				log.Printf("[WARN] failed to unmarshal dedup record, %v", err)
+			}
+			if err == nil && item.DT.Sub(rec.TS).Abs() <= window {
+				item.DuplicateOf = rec.Link
+				log.Printf("[INFO] duplicate %s of %s in %s", item.Link, rec.Link, fmFeed)
+				return nil
+			}
+		}
+
+		keys, err := dedupKeys(bucket)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(dedupRecord{Key: string(key), Link: item.Link, TS: item.DT})
+		if err != nil {
+			return err
+		}
+		if err = bucket.Put([]byte(canonLink), data); err != nil {
+			return err
+		}
+		return keys.Put(key, []byte(canonLink))
+	}
+}
+
+// dedupKeys returns keys bucket of the feed's dedup bucket. Made on the first use, filled from existing
+// records saved before it was introduced.
+func dedupKeys(bucket *bolt.Bucket) (*bolt.Bucket, error) {
+	if keys := bucket.Bucket([]byte(dedupKeysBucket)); keys != nil {
+		return keys, nil
+	}
+	keys, err := bucket.CreateBucket([]byte(dedupKeysBucket))
+	if err != nil {
+		return nil, err
+	}
+	links := map[string][]byte{}
+	err = bucket.ForEach(func(k, v []byte) error {
+		rec := dedupRecord{}
+		if v != nil && json.Unmarshal(v, &rec) == nil {
+			links[rec.Key] = append([]byte(nil), k...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for k, link := range links {
+		if err = keys.Put([]byte(k), link); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// removeDedup drops dedup record of the removed item, unless the link was recorded again for another item.
+// Called within removeKeys transaction.
+func removeDedup(tx *bolt.Tx, fmFeed string, key []byte) error {
+	root := tx.Bucket([]byte(dedupBucket))
+	if root == nil {
+		return nil
+	}
+	bucket := root.Bucket([]byte(fmFeed))
+	if bucket == nil {
+		return nil
+	}
+	keys, err := dedupKeys(bucket)
+	if err != nil {
+		return err
+	}
+	link := keys.Get(key)
+	if link == nil {
+		return nil
+	}
+	link = append([]byte(nil), link...)
+	if err = keys.Delete(key); err != nil {
+		return err
+	}
+	rec := dedupRecord{}
+	if v := bucket.Get(link); v != nil && (json.Unmarshal(v, &rec) != nil || rec.Key == string(key)) {
+		return bucket.Delete(link)
+	}
+	return nil
+}
+
+// resolveLink follows known redirectors to the target link, returns the link as is for anything else
+func resolveLink(ctx context.Context, link string) string {
+	u, err := url.Parse(link)
+	if err != nil || !redirectors[strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")] {
+		return link
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, http.NoBody)
+	if err != nil {
+		return link
+	}
+	resp, err := pageClient.Do(req)
+	if err != nil {
+		log.Printf("[DEBUG] can't resolve %s, %v", link, err)
+		return link
+	}
+	_ = resp.Body.Close()
+	return resp.Request.URL.String()
+}
+
+// canonicalLink normalizes link to compare items from different sources. Scheme, www., fragment,
+// tracking params and trailing slash are dropped, the rest of query is sorted.
+func canonicalLink(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return link
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	query := u.Query()
+
+	// google redirect links keep the target in the query
+	if (host == "google.com" && u.Path == "/url") || host == "news.google.com" {
+		for _, param := range []string{"url", "q"} {
+			if target := query.Get(param); strings.HasPrefix(target, "http") {
+				return canonicalLink(target)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if strings.HasPrefix(strings.ToLower(k), "utm_") || trackingParams[strings.ToLower(k)] {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			params = append(params, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+
+	res := host
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		res += ":" + port
+	}
+	res += strings.TrimRight(u.EscapedPath(), "/")
+	if len(params) > 0 {
+		res += "?" + strings.Join(params, "&")
+	}
+	return res
+}
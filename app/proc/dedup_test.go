@@ -0,0 +1,133 @@
+package proc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+func TestCanonicalLink(t *testing.T) {
+	tbl := []struct {
+		link, want string
+	}{
+		{"https://example.com/post", "example.com/post"},
+		{"http://www.Example.com/post/", "example.com/post"},
+		{"https://example.com:443/post#comments", "example.com/post"},
+		{"https://example.com:8080/post", "example.com:8080/post"},
+		{"https://example.com/post?utm_source=rss&utm_medium=feed", "example.com/post"},
+		{"https://example.com/post?ref=hn&fbclid=abc&id=5", "example.com/post?id=5"},
+		{"https://example.com/post?b=2&a=1", "example.com/post?a=1&b=2"},
+		{"https://example.com/list?source=github", "example.com/list?source=github"},
+		{"https://example.com/a%20b", "example.com/a%20b"},
+		{"https://www.google.com/url?q=https://example.com/post/&sa=D", "example.com/post"},
+		{"https://news.google.com/articles?url=https://www.example.com/post?utm_campaign=x", "example.com/post"},
+		{"not a link", "not a link"},
+		{"", ""},
+	}
+	for _, tt := range tbl {
+		if got := canonicalLink(tt.link); got != tt.want {
+			t.Errorf("canonicalLink(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestDedupCheck(t *testing.T) {
+	store := testStore(t)
+
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	save := func(guid, link string, junk bool, dt time.Time) feed.Item {
+		item := feed.Item{GUID: guid, Link: link, DT: dt, PubDate: dt.Format(time.RFC1123Z), Junk: junk}
+		if _, err := store.SaveItem("f1", &item, DedupCheck(canonicalLink(link), 72*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		return item
+	}
+
+	if item := save("junk", "https://example.com/post?utm_source=hn", true, ts); item.DuplicateOf != "" {
+		t.Errorf("junk item marked as duplicate of %q", item.DuplicateOf)
+	}
+	if item := save("first", "https://example.com/post", false, ts.Add(time.Hour)); item.DuplicateOf != "" {
+		t.Errorf("item after junk copy marked as duplicate of %q", item.DuplicateOf)
+	}
+	if item := save("second", "https://www.example.com/post/", false, ts.Add(2*time.Hour)); item.DuplicateOf != "https://example.com/post" {
+		t.Errorf("duplicate of %q, want the first item", item.DuplicateOf)
+	}
+	if item := save("late", "https://example.com/post", false, ts.Add(100*time.Hour)); item.DuplicateOf != "" {
+		t.Errorf("item out of window marked as duplicate of %q", item.DuplicateOf)
+	}
+}
+
+func TestRemoveDedup(t *testing.T) {
+	store := testStore(t)
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// record saved before the keys bucket was introduced
+	legacy := testItem("legacy", ts)
+	legacyKey, err := itemKey(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.DB.Update(func(tx *bolt.Tx) error {
+		bucket, e := tx.CreateBucketIfNotExists([]byte("f1"))
+		if e != nil {
+			return e
+		}
+		if e = bucket.Put(legacyKey, []byte(`{}`)); e != nil {
+			return e
+		}
+		root, e := tx.CreateBucketIfNotExists([]byte(dedupBucket))
+		if e != nil {
+			return e
+		}
+		dd, e := root.CreateBucketIfNotExists([]byte("f1"))
+		if e != nil {
+			return e
+		}
+		return dd.Put([]byte("example.com/legacy"), []byte(`{"key":"`+string(legacyKey)+`","link":"https://example.com/legacy"}`))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, guid := range []string{"old", "new"} {
+		item := testItem(guid, ts.Add(time.Duration(i+1)*time.Hour))
+		if _, err = store.SaveItem("f1", &item, DedupCheck(canonicalLink(item.Link), 72*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the same link recorded again for the item out of window, removal of the old item keeps it
+	late := testItem("late", ts.Add(100*time.Hour))
+	late.Link = "https://example.com/old"
+	if _, err = store.SaveItem("f1", &late, DedupCheck(canonicalLink(late.Link), 72*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.removeOld("f1", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.DB.View(func(tx *bolt.Tx) error {
+		dd := tx.Bucket([]byte(dedupBucket)).Bucket([]byte("f1"))
+		var links []string
+		_ = dd.ForEach(func(k, v []byte) error {
+			if v != nil {
+				links = append(links, string(k))
+			}
+			return nil
+		})
+		if want := []string{"example.com/new", "example.com/old"}; !reflect.DeepEqual(links, want) {
+			t.Errorf("links %q, want %q", links, want)
+		}
+		if n := dd.Bucket([]byte(dedupKeysBucket)).Stats().KeyN; n != 2 {
+			t.Errorf("%d keys, want 2", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
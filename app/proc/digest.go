@@ -0,0 +1,197 @@
+package proc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/message"
+)
+
+// digest buckets, pending items are in nested bucket per feed keyed by item key
+const (
+	digestBucket     = "_digest"
+	digestLastBucket = "_digest_last" // feed name to time of the last digest
+)
+
+// sendDigests enqueues digests of all feeds in digest mode with due schedule. Sources change only the topic
+// of destinations, so a feed without destinations of the empty source has none at all.
+func (p *Processor) sendDigests(now time.Time) {
+	for name, fm := range p.Conf.Feeds { //nolint
+		if !fm.Delivery.Digest() || len(fm.Destinations("")) == 0 {
+			continue
+		}
+		if err := p.Store.flushDigest(name, fm, now); err != nil {
+			log.Printf("[WARN] failed to send digest of %s, %v", name, err)
+		}
+	}
+}
+
+// addDigestTx keeps the item until the next digest of the feed, within the transaction
+func addDigestTx(tx *bolt.Tx, fmFeed string, item feed.Item) error {
+	key, err := itemKey(item)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	root, err := tx.CreateBucketIfNotExists([]byte(digestBucket))
+	if err != nil {
+		return err
+	}
+	bucket, err := root.CreateBucketIfNotExists([]byte(fmFeed))
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+// flushDigest enqueues digest of pending items if it's due, pending items are removed in the same transaction.
+// Each destination gets its own digest of items of sources sent there, like to the source topic.
+// The first call only records the time to schedule digests from.
+func (b BoltDB) flushDigest(fmFeed string, fm config.Feed, now time.Time) error {
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		lastBucket, err := tx.CreateBucketIfNotExists([]byte(digestLastBucket))
+		if err != nil {
+			return err
+		}
+		last := time.Time{}
+		if v := lastBucket.Get([]byte(fmFeed)); v != nil {
+			if err = last.UnmarshalText(v); err != nil {
+				log.Printf("[WARN] bad time of the last digest of %s, %v", fmFeed, err)
+			}
+		}
+		if !last.IsZero() {
+			next := fm.Delivery.NextDigest(last)
+			if next.IsZero() || now.Before(next) {
+				return nil
+			}
+		}
+		ts, err := now.MarshalText()
+		if err != nil {
+			return err
+		}
+		if err = lastBucket.Put([]byte(fmFeed), ts); err != nil {
+			return err
+		}
+		if last.IsZero() {
+			return nil
+		}
+
+		root := tx.Bucket([]byte(digestBucket))
+		if root == nil || root.Bucket([]byte(fmFeed)) == nil {
+			return nil
+		}
+		var items []feed.Item
+		err = root.Bucket([]byte(fmFeed)).ForEach(func(_, v []byte) error {
+			item := feed.Item{}
+			if e := json.Unmarshal(v, &item); e != nil {
+				log.Printf("[WARN] failed to unmarshal digest item, %v", e)
+				return nil
+			}
+			items = append(items, item)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err = root.DeleteBucket([]byte(fmFeed)); err != nil {
+			return err
+		}
+
+		title := fm.Title
+		if title == "" {
+			title = fmFeed
+		}
+		var dsts []config.Destination
+		grouped := map[config.Destination][]feed.Item{}
+		for _, item := range items {
+			for _, dst := range fm.Destinations(item.Source) {
+				if _, ok := grouped[dst]; !ok {
+					dsts = append(dsts, dst)
+				}
+				grouped[dst] = append(grouped[dst], item)
+			}
+		}
+		for _, dst := range dsts {
+			dstItems := grouped[dst]
+			msgs := digestMessages(title, selectDigest(dstItems, fm.Delivery.MaxItems, fm.Scoring.Enabled), message.MaxLength)
+			log.Printf("[INFO] digest of %s to %s, %d of %d items in %d messages", fmFeed, dst.Chat,
+				min(len(dstItems), fm.Delivery.MaxItems), len(dstItems), len(msgs))
+			for _, m := range msgs {
+				if err = enqueueTx(tx, outboxEntry{Feed: fmFeed, Chat: dst.Chat, Topic: dst.Topic, Text: m}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// selectDigest returns up to maxItems best items by score if scored, newest otherwise
+func selectDigest(items []feed.Item, maxItems int, scored bool) []feed.Item {
+	sort.SliceStable(items, func(i, j int) bool {
+		if scored && items[i].Score != items[j].Score {
+			return items[i].Score > items[j].Score
+		}
+		return items[i].DT.After(items[j].DT)
+	})
+	if len(items) > maxItems {
+		items = items[:maxItems]
+	}
+	return items
+}
+
+// digestMessages makes digest of items, a line per item, split to messages of up to maxLen UTF-16 code units
+func digestMessages(title string, items []feed.Item, maxLen int) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		line := fmt.Sprintf(`• <a href="%s">%s</a>`, message.Escape(item.Link), message.Escape(strings.TrimSpace(item.Title)))
+		if item.Source != "" {
+			line += " <i>" + message.Escape(item.Source) + "</i>"
+		}
+		lines = append(lines, line)
+	}
+
+	// header is added later, with part numbers if split
+	header := func(part, parts int) string {
+		if parts == 1 {
+			return fmt.Sprintf("<b>%s</b>, %d items\n\n", message.Escape(title), len(items))
+		}
+		return fmt.Sprintf("<b>%s</b>, %d items (%d/%d)\n\n", message.Escape(title), len(items), part, parts)
+	}
+	budget := maxLen - message.Length(header(99, 99))
+
+	var chunks []string
+	var sb strings.Builder
+	for _, line := range lines {
+		line = message.Fit(line, budget) // can't be cut with telegram limits, titles are short
+		if sb.Len() > 0 && message.Length(sb.String())+1+message.Length(line) > budget {
+			chunks = append(chunks, sb.String())
+			sb.Reset()
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(line)
+	}
+	chunks = append(chunks, sb.String())
+
+	res := make([]string, len(chunks))
+	for i, c := range chunks {
+		res[i] = header(i+1, len(chunks)) + c
+	}
+	return res
+}
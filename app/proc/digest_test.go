@@ -0,0 +1,118 @@
+package proc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/message"
+)
+
+func TestFlushDigest(t *testing.T) {
+	confFile := filepath.Join(t.TempDir(), "conf.yml")
+	err := os.WriteFile(confFile, []byte(`
+feeds:
+  news:
+    telegram_group_id: "@chan"
+    telegram_topic_id: 1
+    telegram_destinations: [{chat: "-100"}]
+    delivery: {mode: digest, schedule: "0 * * * *", timezone: UTC}
+    sources:
+      - {name: s1, url: "https://example.com/1"}
+      - {name: s2, url: "https://example.com/2", telegram_topic_id: 2}
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := config.Load(confFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm := conf.Feeds["news"]
+
+	store := testStore(t)
+	start := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	if err = store.flushDigest("news", fm, start); err != nil { // schedule starts
+		t.Fatal(err)
+	}
+	for _, item := range []feed.Item{testItem("a", start), testItem("b", start.Add(time.Minute))} {
+		item.Source = map[string]string{"a": "s1", "b": "s2"}[item.GUID]
+		if err = store.DB.Update(func(tx *bolt.Tx) error { return addDigestTx(tx, "news", item) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = store.flushDigest("news", fm, start.Add(20*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := store.dueEntries(time.Now()); len(entries) != 0 {
+		t.Fatalf("%d entries enqueued before schedule", len(entries))
+	}
+
+	if err = store.flushDigest("news", fm, start.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := store.dueEntries(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[config.Destination]string{}
+	for _, e := range entries {
+		got[config.Destination{Chat: e.Chat, Topic: e.Topic}] = e.Text
+	}
+	if len(entries) != 3 || len(got) != 3 {
+		t.Fatalf("got %+v, want digests to 3 destinations", got)
+	}
+	if s := got[config.Destination{Chat: "@chan", Topic: 1}]; !strings.Contains(s, "title a") || strings.Contains(s, "title b") {
+		t.Errorf("bad digest to the feed topic %q", s)
+	}
+	if s := got[config.Destination{Chat: "@chan", Topic: 2}]; strings.Contains(s, "title a") || !strings.Contains(s, "title b") {
+		t.Errorf("bad digest to the source topic %q", s)
+	}
+	if s := got[config.Destination{Chat: "-100"}]; !strings.Contains(s, "title a") || !strings.Contains(s, "title b") {
+		t.Errorf("bad digest to other destination %q", s)
+	}
+}
+
+func TestDigestMessages(t *testing.T) {
+	items := []feed.Item{
+		{Title: "first", Link: "https://example.com/1", Source: "s1"},
+		{Title: "second & more", Link: "https://example.com/2?a=1&b=2"},
+		{Title: strings.Repeat("😀", 100), Link: "https://example.com/3"},
+	}
+	tbl := []struct {
+		name   string
+		maxLen int
+		parts  int
+	}{
+		{name: "single message", maxLen: message.MaxLength, parts: 1},
+		{name: "line per message", maxLen: 120, parts: 3},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs := digestMessages("news", items, tt.maxLen)
+			if len(msgs) != tt.parts {
+				t.Fatalf("%d messages %q, want %d", len(msgs), msgs, tt.parts)
+			}
+			for _, m := range msgs {
+				if message.Length(m) > tt.maxLen {
+					t.Errorf("length %d over %d, %q", message.Length(m), tt.maxLen, m)
+				}
+				if strings.Count(m, "<a ") != strings.Count(m, "</a>") {
+					t.Errorf("broken link tag in %q", m)
+				}
+			}
+		})
+	}
+	if !strings.Contains(digestMessages("news", items[:2], 1000)[0], `<a href="https://example.com/2?a=1&amp;b=2">second &amp; more</a>`) {
+		t.Error("link not escaped")
+	}
+	if msgs := digestMessages("news", nil, 1000); msgs != nil {
+		t.Errorf("got %q for no items", msgs)
+	}
+}
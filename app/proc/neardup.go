@@ -0,0 +1,127 @@
+package proc
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math/bits"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/search"
+)
+
+// simhashBucket keeps signatures of items, nested bucket per feed with the same keys as items
+const simhashBucket = "_simhash"
+
+// minSimhashFeatures is the minimal number of features to make a signature, short texts are too similar
+const minSimhashFeatures = 6
+
+// simhashRecord is a value of simhash bucket
+type simhashRecord struct {
+	Hash uint64    `json:"hash"`
+	Link string    `json:"link"`
+	TS   time.Time `json:"ts"`
+}
+
+// NearDupCheck marks item as similar to another item saved to the feed within the window,
+// if SimHash signatures of their normalized title and description differ by distance bits or less.
+// Signature of each item is recorded, junk items are neither checked nor recorded.
+func NearDupCheck(distance int, window time.Duration) SaveCheck {
+	return func(tx *bolt.Tx, fmFeed string, key []byte, item *feed.Item) error {
+		if item.Junk {
+			return nil
+		}
+		hash, ok := simhash(item.Title + " " + string(item.Description))
+		if !ok {
+			return nil
+		}
+		root, err := tx.CreateBucketIfNotExists([]byte(simhashBucket))
+		if err != nil {
+			return err
+		}
+		bucket, err := root.CreateBucketIfNotExists([]byte(fmFeed))
+		if err != nil {
+			return err
+		}
+
+		if item.DuplicateOf == "" {
+			c := bucket.Cursor()
+			for k, v := c.Last(); k != nil; k, v = c.Prev() {
+				rec := simhashRecord{}
+				if e := json.Unmarshal(v, &rec); e != nil {
+					continue
+				}
+				if item.DT.Sub(rec.TS) > window {
+					break // keys are sorted by time, the rest is older
+				}
+				if rec.TS.Sub(item.DT) > window {
+					continue
+				}
+				if d := bits.OnesCount64(hash ^ rec.Hash); d <= distance {
+					item.SimilarTo = rec.Link
+					log.Printf("[INFO] near-duplicate %s of %s in %s, distance %d", item.Link, rec.Link, fmFeed, d)
+					break
+				}
+			}
+		}
+
+		data, err := json.Marshal(simhashRecord{Hash: hash, Link: item.Link, TS: item.DT})
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, data)
+	}
+}
+
+// removeSimhash drops signature of the removed item, called within removeOld transaction
+func removeSimhash(tx *bolt.Tx, fmFeed string, key []byte) error {
+	root := tx.Bucket([]byte(simhashBucket))
+	if root == nil {
+		return nil
+	}
+	bucket := root.Bucket([]byte(fmFeed))
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Delete(key)
+}
+
+// simhash makes 64-bit SimHash of text with words and word pairs as features.
+// Returns false if the text is too short for a meaningful signature.
+func simhash(text string) (uint64, bool) {
+	words := search.Tokenize(text)
+	if len(words)*2-1 < minSimhashFeatures {
+		return 0, false
+	}
+
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+				continue
+			}
+			weights[i]--
+		}
+	}
+	for i, w := range words {
+		add(w)
+		if i > 0 {
+			add(words[i-1] + " " + w)
+		}
+	}
+
+	var res uint64
+	for i, w := range weights {
+		if w > 0 {
+			res |= 1 << uint(i)
+		}
+	}
+	return res, true
+}
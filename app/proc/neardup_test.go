@@ -0,0 +1,73 @@
+package proc
+
+import (
+	"math/bits"
+	"testing"
+	"time"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+func TestSimhash(t *testing.T) {
+	base := "Go 1.23 released with range over function iterators and new telemetry"
+	tbl := []struct {
+		name             string
+		text             string
+		ok               bool
+		minDist, maxDist int
+	}{
+		{name: "same text", text: base, ok: true},
+		{name: "case and punctuation", text: "GO 1.23 RELEASED, with range over function iterators; and new telemetry!", ok: true},
+		{name: "one word changed", text: "Go 1.23 released with range over function iterators and new tooling", ok: true, maxDist: 12},
+		{name: "different text", text: "Rust 2024 edition stabilizes async closures and let chains in the compiler", ok: true,
+			minDist: 13, maxDist: 64},
+		{name: "too short", text: "Go released", ok: false},
+	}
+	baseHash, ok := simhash(base)
+	if !ok {
+		t.Fatal("no signature of base text")
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, ok := simhash(tt.text)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			d := bits.OnesCount64(hash ^ baseHash)
+			if d < tt.minDist || d > tt.maxDist {
+				t.Errorf("distance %d, want %d..%d", d, tt.minDist, tt.maxDist)
+			}
+		})
+	}
+}
+
+func TestNearDupCheck(t *testing.T) {
+	store := testStore(t)
+
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	text := "Go 1.23 released with range over function iterators and new telemetry"
+	save := func(guid string, distance int, junk bool, dt time.Time) feed.Item {
+		item := feed.Item{GUID: guid, Link: "https://example.com/" + guid, Title: text, DT: dt,
+			PubDate: dt.Format(time.RFC1123Z), Junk: junk}
+		if _, err := store.SaveItem("f1", &item, NearDupCheck(distance, 48*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		return item
+	}
+
+	if item := save("junk", 3, true, ts); item.SimilarTo != "" {
+		t.Errorf("junk item marked as similar to %q", item.SimilarTo)
+	}
+	if item := save("first", 3, false, ts.Add(time.Hour)); item.SimilarTo != "" {
+		t.Errorf("item after junk copy marked as similar to %q", item.SimilarTo)
+	}
+	if item := save("exact", 0, false, ts.Add(2*time.Hour)); item.SimilarTo != "https://example.com/first" {
+		t.Errorf("similar to %q with distance 0, want the first item", item.SimilarTo)
+	}
+	if item := save("late", 3, false, ts.Add(100*time.Hour)); item.SimilarTo != "" {
+		t.Errorf("item out of window marked as similar to %q", item.SimilarTo)
+	}
+}
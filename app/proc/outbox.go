@@ -0,0 +1,430 @@
+package proc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	bolt "go.etcd.io/bbolt"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/message"
+)
+
+// outbox buckets, entries are keyed by sequence to be sent in order
+const (
+	outboxBucket    = "_outbox"
+	deadBucket      = "_outbox_dead" // entries failed permanently or too many times
+	deliveredBucket = "_delivered"   // telegram messages of sent items, nested bucket per feed, keyed by item key
+)
+
+// outbox retry policy
+const (
+	outboxMaxAttempts = 10
+	outboxBaseDelay   = 5 * time.Second
+	outboxMaxDelay    = time.Hour
+	outboxPoll        = time.Second
+)
+
+// outboxEntry is a pending delivery of the item to the chat
+type outboxEntry struct {
+	Seq         uint64    `json:"seq"`
+	Feed        string    `json:"feed"`
+	Chat        string    `json:"chat"`
+	Topic       int       `json:"topic,omitempty"`
+	Item        feed.Item `json:"item"`
+	Text        string    `json:"text,omitempty"` // ready message, like digest, sent instead of the item
+	SourceTitle string    `json:"source_title"`
+	SourceLink  string    `json:"source_link,omitempty"`
+	Action      string    `json:"action,omitempty"`     // edit or delete of the sent message, send if empty
+	MessageID   int       `json:"message_id,omitempty"` // of edited or deleted message
+	Photo       bool      `json:"photo,omitempty"`      // edited message is a photo
+	Note        string    `json:"note,omitempty"`       // appended to edited message
+	Subscriber  bool      `json:"subscriber,omitempty"` // private chat of personal subscription
+	Created     time.Time `json:"created"`
+	NextAt      time.Time `json:"next_at"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// delivery is a record of the sent message, an item has one per destination
+type delivery struct {
+	Chat      string    `json:"chat"`
+	Topic     int       `json:"topic,omitempty"`
+	MessageID int       `json:"message_id"`
+	Photo     bool      `json:"photo,omitempty"`
+	Sent      time.Time `json:"sent"`
+	Vanished  bool      `json:"vanished,omitempty"` // item gone from the source, message deleted or annotated
+}
+
+// OutboxStats is a number of pending and dead entries, per feed
+type OutboxStats struct {
+	Pending int            `json:"pending"`
+	Dead    int            `json:"dead"`
+	Feeds   map[string]int `json:"feeds_pending"`
+	Failed  map[string]int `json:"feeds_dead"`
+}
+
+// Deliver drains the outbox until ctx is done, retrying failed sends with exponential backoff
+func (p *Processor) Deliver(ctx context.Context) {
+	log.Printf("[INFO] activate outbox sender")
+	for {
+		p.sendDigests(time.Now())
+		p.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(outboxPoll):
+		}
+	}
+}
+
+// deliverDue sends due entries, taking feeds in turn. Chats out of rate limit budget or flooded are skipped
+// until the next call, keeping the order of their entries. So are feeds out of delivery window, unless sent silently,
+// and their messages held since before the window opened are released one per delivery's release interval.
+func (p *Processor) deliverDue(ctx context.Context) {
+	entries, err := p.Store.dueEntries(time.Now())
+	if err != nil {
+		log.Printf("[WARN] failed to load outbox, %v", err)
+		return
+	}
+	if p.released == nil {
+		p.released = map[string]time.Time{}
+	}
+	blocked, held := map[string]bool{}, map[string]bool{}
+	for _, e := range fairOrder(entries) {
+		if blocked[e.Chat] || (held[e.Feed] && !e.Subscriber) { // subscribers are not held by feed's window
+			continue
+		}
+		opts, ok := p.windowOptions(e, time.Now())
+		if !ok {
+			held[e.Feed] = true
+			continue
+		}
+		opts.ThreadID = e.Topic
+		if !p.waitBudget(ctx, e.Chat) {
+			if ctx.Err() != nil {
+				return
+			}
+			blocked[e.Chat] = true
+			continue
+		}
+		sent, err := p.sendEntry(e, opts)
+		if err == nil {
+			if err = p.Store.markDelivered(e, sent); err != nil {
+				log.Printf("[WARN] failed to mark %s in %s as delivered, %v", e.Item.GUID, e.Feed, err)
+			}
+			continue
+		}
+
+		e.Attempts++
+		e.LastError = err.Error()
+		delay := backoff(e.Attempts)
+		var flood tb.FloodError
+		isFlood := errors.As(err, &flood)
+		if isFlood {
+			delay = time.Duration(flood.RetryAfter) * time.Second
+		}
+		if !isFlood && (permanent(err) || e.Attempts >= outboxMaxAttempts) {
+			log.Printf("[WARN] failed to send %s of %s to %s, attempt %d, giving up, %v", e.Item.GUID, e.Feed, e.Chat, e.Attempts, err)
+			if e.Subscriber && blockedBot(err) {
+				p.dropSubscriber(e.Chat)
+			}
+			if err = p.Store.bury(e); err != nil {
+				log.Printf("[WARN] failed to move %s to dead letters, %v", e.Item.GUID, err)
+			}
+			continue
+		}
+		log.Printf("[WARN] failed to send %s of %s to %s, attempt %d, retry in %s, %v", e.Item.GUID, e.Feed, e.Chat, e.Attempts, delay, err)
+		e.NextAt = time.Now().Add(delay)
+		if err = p.Store.reschedule(e); err != nil {
+			log.Printf("[WARN] failed to reschedule %s, %v", e.Item.GUID, err)
+		}
+		if isFlood {
+			blocked[e.Chat] = true
+			if p.Limiter != nil {
+				p.Limiter.Drain(e.Chat)
+			}
+		}
+	}
+}
+
+// windowOptions checks delivery window of the entry's feed, returns false if the entry should be held.
+// Out of window entries of silent feeds are sent without notification, subscribers get messages any time.
+func (p *Processor) windowOptions(e outboxEntry, now time.Time) (SendOptions, bool) {
+	if e.Subscriber {
+		return SendOptions{}, true // windows are for groups
+	}
+	d := p.Conf.Feeds[e.Feed].Delivery
+	start, open := d.WindowStart(now)
+	if !open {
+		return SendOptions{Silent: true}, d.Silent()
+	}
+	if e.Created.Before(start) {
+		if last, ok := p.released[e.Feed]; ok && now.Sub(last) < d.Release {
+			return SendOptions{}, false
+		}
+		p.released[e.Feed] = now
+	}
+	return SendOptions{}, true
+}
+
+// sendEntry sends ready text of the entry or the item made with the feed template, or edits or deletes
+// the sent message
+func (p *Processor) sendEntry(e outboxEntry, opts SendOptions) (Sent, error) {
+	if e.Action == actionDelete {
+		return Sent{}, p.TelegramNotif.Delete(e.Chat, e.MessageID)
+	}
+	if e.Text != "" {
+		msgID, err := p.TelegramNotif.SendText(e.Chat, e.Text, opts)
+		return Sent{MessageID: msgID}, err
+	}
+	data := message.Data{Item: e.Item, FeedName: e.Feed, SourceTitle: e.SourceTitle, SourceLink: e.SourceLink}
+	opts.Post = p.Conf.Feeds[e.Feed].TelegramPost
+	if e.Action == actionEdit {
+		opts.Photo, opts.Note = e.Photo, e.Note
+		return Sent{MessageID: e.MessageID, Photo: e.Photo}, p.TelegramNotif.Edit(e.Chat, e.MessageID, p.Conf.Feeds[e.Feed].Message(), data, opts)
+	}
+	return p.TelegramNotif.Send(e.Chat, p.Conf.Feeds[e.Feed].Message(), data, opts)
+}
+
+// waitBudget takes rate limit token for the chat, waiting for the global budget if needed.
+// Returns false if the chat is out of its own budget or ctx is done.
+func (p *Processor) waitBudget(ctx context.Context, chat string) bool {
+	if p.Limiter == nil {
+		return true
+	}
+	for {
+		ok, wait := p.Limiter.Allow(chat, time.Now())
+		if ok {
+			return true
+		}
+		if wait == 0 {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(wait):
+		}
+	}
+}
+
+// fairOrder interleaves entries of different feeds, one of each feed in turn, keeping order within a feed
+func fairOrder(entries []outboxEntry) []outboxEntry {
+	var feeds []string
+	byFeed := map[string][]outboxEntry{}
+	for _, e := range entries {
+		if _, ok := byFeed[e.Feed]; !ok {
+			feeds = append(feeds, e.Feed)
+		}
+		byFeed[e.Feed] = append(byFeed[e.Feed], e)
+	}
+	res := make([]outboxEntry, 0, len(entries))
+	for i := 0; len(res) < len(entries); i++ {
+		for _, f := range feeds {
+			if i < len(byFeed[f]) {
+				res = append(res, byFeed[f][i])
+			}
+		}
+	}
+	return res
+}
+
+// backoff returns delay before the next attempt, doubled each time up to outboxMaxDelay
+func backoff(attempts int) time.Duration {
+	res := outboxBaseDelay
+	for i := 1; i < attempts && res < outboxMaxDelay; i++ {
+		res *= 2
+	}
+	return min(res, outboxMaxDelay)
+}
+
+// permanent checks if the error won't go away on retry, like bad request or bot kicked from the chat
+func permanent(err error) bool {
+	var tbErr *tb.Error
+	if errors.As(err, &tbErr) {
+		return tbErr.Code == 400 || tbErr.Code == 403
+	}
+	return blockedBot(err)
+}
+
+// enqueueTx adds the entry to the outbox within the transaction, sequence and times are set here
+func enqueueTx(tx *bolt.Tx, e outboxEntry) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(outboxBucket))
+	if err != nil {
+		return err
+	}
+	if e.Seq, err = bucket.NextSequence(); err != nil {
+		return err
+	}
+	e.Created = time.Now()
+	e.NextAt = e.Created
+	return putEntry(bucket, e)
+}
+
+// dueEntries returns outbox entries ready to be sent at the time, in order
+func (b BoltDB) dueEntries(now time.Time) ([]outboxEntry, error) {
+	var res []outboxEntry
+	err := b.DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(outboxBucket))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, v []byte) error {
+			e := outboxEntry{}
+			if err := json.Unmarshal(v, &e); err != nil {
+				log.Printf("[WARN] failed to unmarshal outbox entry, %v", err)
+				return nil
+			}
+			if !e.NextAt.After(now) {
+				res = append(res, e)
+			}
+			return nil
+		})
+	})
+	return res, err
+}
+
+// markDelivered removes the entry from the outbox and records the sent message, replacing the previous one
+// sent to the same destination. Edits and deletes are recorded when enqueued.
+func (b BoltDB) markDelivered(e outboxEntry, msg Sent) error {
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(outboxBucket)); bucket != nil {
+			if err := bucket.Delete(seqKey(e.Seq)); err != nil {
+				return err
+			}
+		}
+		if e.Action != "" {
+			return nil
+		}
+		key, err := itemKey(e.Item)
+		if err != nil {
+			return nil // nothing to record delivery by
+		}
+		root, err := tx.CreateBucketIfNotExists([]byte(deliveredBucket))
+		if err != nil {
+			return err
+		}
+		bucket, err := root.CreateBucketIfNotExists([]byte(e.Feed))
+		if err != nil {
+			return err
+		}
+		d := delivery{Chat: e.Chat, Topic: e.Topic, MessageID: msg.MessageID, Photo: msg.Photo, Sent: time.Now()}
+		sent := slices.DeleteFunc(deliveries(bucket, key), func(s delivery) bool { return s.Chat == d.Chat && s.Topic == d.Topic })
+		return putDeliveries(bucket, key, append(sent, d))
+	})
+}
+
+// deliveries returns sent messages of the item key from the feed's delivered bucket.
+// Records made before multiple destinations have a single delivery.
+func deliveries(bucket *bolt.Bucket, key []byte) []delivery {
+	v := bucket.Get(key)
+	if v == nil {
+		return nil
+	}
+	var res []delivery
+	if err := json.Unmarshal(v, &res); err == nil {
+		return res
+	}
+	var single delivery
+	if err := json.Unmarshal(v, &single); err != nil {
+		log.Printf("[WARN] failed to unmarshal deliveries of %s, %v", key, err)
+		return nil
+	}
+	return []delivery{single}
+}
+
+func putDeliveries(bucket *bolt.Bucket, key []byte, ds []delivery) error {
+	data, err := json.Marshal(ds)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+// reschedule updates the entry in the outbox
+func (b BoltDB) reschedule(e outboxEntry) error {
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(outboxBucket))
+		if err != nil {
+			return err
+		}
+		return putEntry(bucket, e)
+	})
+}
+
+// bury moves the entry from the outbox to dead letters
+func (b BoltDB) bury(e outboxEntry) error {
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(outboxBucket)); bucket != nil {
+			if err := bucket.Delete(seqKey(e.Seq)); err != nil {
+				return err
+			}
+		}
+		bucket, err := tx.CreateBucketIfNotExists([]byte(deadBucket))
+		if err != nil {
+			return err
+		}
+		return putEntry(bucket, e)
+	})
+}
+
+// OutboxStats returns number of pending and dead entries
+func (b BoltDB) OutboxStats() (OutboxStats, error) {
+	res := OutboxStats{Feeds: map[string]int{}, Failed: map[string]int{}}
+	err := b.DB.View(func(tx *bolt.Tx) error {
+		count := func(name string, total *int, byFeed map[string]int) error {
+			bucket := tx.Bucket([]byte(name))
+			if bucket == nil {
+				return nil
+			}
+			return bucket.ForEach(func(_, v []byte) error {
+				e := outboxEntry{}
+				if err := json.Unmarshal(v, &e); err != nil {
+					return nil
+				}
+				*total++
+				byFeed[e.Feed]++
+				return nil
+			})
+		}
+		if err := count(outboxBucket, &res.Pending, res.Feeds); err != nil {
+			return err
+		}
+		return count(deadBucket, &res.Dead, res.Failed)
+	})
+	return res, err
+}
+
+// removeDelivered drops delivery record of the item, called within removeOld transaction
+func removeDelivered(tx *bolt.Tx, fmFeed string, key []byte) error {
+	root := tx.Bucket([]byte(deliveredBucket))
+	if root == nil {
+		return nil
+	}
+	bucket := root.Bucket([]byte(fmFeed))
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Delete(key)
+}
+
+func putEntry(bucket *bolt.Bucket, e outboxEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(seqKey(e.Seq), data)
+}
+
+func seqKey(seq uint64) []byte {
+	res := make([]byte, 8)
+	binary.BigEndian.PutUint64(res, seq)
+	return res
+}
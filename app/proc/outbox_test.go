@@ -0,0 +1,273 @@
+package proc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/message"
+)
+
+// notifMock records sent items, sends to chats listed in errs fail with the error
+type notifMock struct {
+	mu   sync.Mutex
+	sent []string // chat:guid
+	errs map[string]error
+}
+
+func (n *notifMock) Send(chanID string, _ *message.Template, data message.Data, _ SendOptions) (Sent, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.errs[chanID]; err != nil {
+		return Sent{}, err
+	}
+	n.sent = append(n.sent, chanID+":"+data.GUID)
+	return Sent{MessageID: len(n.sent)}, nil
+}
+
+func (n *notifMock) SendText(chanID, text string, _ SendOptions) (int, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, chanID+":"+text)
+	return len(n.sent), nil
+}
+
+func (n *notifMock) Edit(string, int, *message.Template, message.Data, SendOptions) error { return nil }
+
+func (n *notifMock) Delete(string, int) error { return nil }
+
+func testStore(t *testing.T) *BoltDB {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return &BoltDB{DB: db}
+}
+
+func testItem(guid string, dt time.Time) feed.Item {
+	return feed.Item{GUID: guid, Title: "title " + guid, Link: "https://example.com/" + guid, DT: dt,
+		PubDate: dt.Format(time.RFC1123Z)}
+}
+
+func TestRouteCheckEnqueuesInSaveTransaction(t *testing.T) {
+	store := testStore(t)
+	fm := config.Feed{TelegramGroupID: "@chan", TelegramDestinations: []config.Destination{{Chat: "-100", Topic: 5}}}
+	rss := feed.Rss2{Title: "source", Link: "https://example.com"}
+	subs := map[string]subscription{"42": {}, "43": {Keywords: []string{"nomatch"}}}
+	now := time.Now()
+
+	item := testItem("g1", now)
+	created, err := store.SaveItem("f1", &item, routeCheck(fm, config.Source{Name: "s1"}, rss, true, Overrides{}, subs))
+	if err != nil || !created {
+		t.Fatalf("created %v, %v", created, err)
+	}
+	entries, err := store.dueEntries(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chats := map[string]bool{}
+	for _, e := range entries {
+		chats[e.Chat] = true
+		if e.Item.GUID != "g1" || e.SourceTitle != "source" {
+			t.Errorf("bad entry %+v", e)
+		}
+	}
+	if len(entries) != 3 || !chats["@chan"] || !chats["-100"] || !chats["42"] {
+		t.Fatalf("enqueued to %v, want @chan, -100 and subscriber 42", chats)
+	}
+
+	// failed save leaves neither the item nor its entries
+	failing := func(*bolt.Tx, string, []byte, *feed.Item) error { return errors.New("failed") }
+	item = testItem("g2", now)
+	if _, err = store.SaveItem("f1", &item, routeCheck(fm, config.Source{}, rss, true, Overrides{}, nil), failing); err == nil {
+		t.Fatal("no error")
+	}
+	if store.exists("f1", item) {
+		t.Error("item saved despite failed check")
+	}
+	if entries, _ = store.dueEntries(time.Now()); len(entries) != 3 {
+		t.Errorf("%d entries, want 3", len(entries))
+	}
+
+	// junk, not announced and muted items are saved but not routed
+	muted := Overrides{Muted: map[string]time.Time{"s1": now.Add(time.Hour)}}
+	for i, tt := range []struct {
+		announce bool
+		junk     bool
+		ov       Overrides
+	}{{announce: false}, {announce: true, junk: true}, {announce: true, ov: muted}} {
+		item = testItem(fmt.Sprintf("n%d", i), now)
+		item.Junk = tt.junk
+		if _, err = store.SaveItem("f1", &item, routeCheck(fm, config.Source{Name: "s1"}, rss, tt.announce, tt.ov, subs)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries, _ = store.dueEntries(time.Now()); len(entries) != 3 {
+		t.Errorf("%d entries, want 3", len(entries))
+	}
+}
+
+func TestDeliverDue(t *testing.T) {
+	store := testStore(t)
+	notif := &notifMock{errs: map[string]error{
+		"retry": errors.New("network error"),
+		"bad":   &tb.Error{Code: 400, Description: "Bad Request: chat not found"},
+	}}
+	conf := &config.Conf{Feeds: map[string]config.Feed{"f1": {}, "f2": {}}}
+	p := &Processor{Conf: conf, Store: store, TelegramNotif: notif}
+
+	now := time.Now()
+	err := store.DB.Update(func(tx *bolt.Tx) error {
+		for _, e := range []outboxEntry{
+			{Feed: "f1", Chat: "ok", Item: testItem("a1", now)},
+			{Feed: "f1", Chat: "ok", Item: testItem("a2", now)},
+			{Feed: "f2", Chat: "ok", Item: testItem("b1", now)},
+			{Feed: "f2", Chat: "retry", Item: testItem("b2", now)},
+			{Feed: "f2", Chat: "bad", Item: testItem("b3", now)},
+		} {
+			if err := enqueueTx(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.deliverDue(context.Background())
+	want := []string{"ok:a1", "ok:b1", "ok:a2"} // feeds in turn
+	if len(notif.sent) != len(want) {
+		t.Fatalf("sent %v, want %v", notif.sent, want)
+	}
+	for i := range want {
+		if notif.sent[i] != want[i] {
+			t.Fatalf("sent %v, want %v", notif.sent, want)
+		}
+	}
+
+	stats, err := store.OutboxStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pending != 1 || stats.Dead != 1 || stats.Failed["f2"] != 1 {
+		t.Errorf("stats %+v, want one pending retry and one dead", stats)
+	}
+	if entries, _ := store.dueEntries(time.Now()); len(entries) != 0 {
+		t.Errorf("retry is due immediately, %+v", entries)
+	}
+	entries, _ := store.dueEntries(time.Now().Add(outboxBaseDelay))
+	if len(entries) != 1 || entries[0].Attempts != 1 || entries[0].LastError != "network error" {
+		t.Errorf("bad retry entry %+v", entries)
+	}
+}
+
+func TestDeliverDueHeld(t *testing.T) {
+	// the only window is two days ahead, closed now
+	day := strings.ToLower(time.Now().AddDate(0, 0, 2).Weekday().String()[:3])
+	confFile := filepath.Join(t.TempDir(), "conf.yml")
+	conf := fmt.Sprintf("feeds:\n  f1:\n    delivery: {windows: [{days: [%s], from: \"00:00\", to: \"01:00\"}]}\n", day)
+	if err := os.WriteFile(confFile, []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.Load(confFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := testStore(t)
+	notif := &notifMock{}
+	p := &Processor{Conf: c, Store: store, TelegramNotif: notif}
+
+	now := time.Now()
+	err = store.DB.Update(func(tx *bolt.Tx) error {
+		for _, e := range []outboxEntry{
+			{Feed: "f1", Chat: "@chan", Item: testItem("g1", now)},
+			{Feed: "f1", Chat: "42", Item: testItem("s1", now), Subscriber: true},
+		} {
+			if err := enqueueTx(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.deliverDue(context.Background())
+	if len(notif.sent) != 1 || notif.sent[0] != "42:s1" {
+		t.Errorf("sent %v, want the subscriber's message only", notif.sent)
+	}
+	if entries, _ := store.dueEntries(time.Now()); len(entries) != 1 || entries[0].Item.GUID != "g1" {
+		t.Errorf("pending %+v, want the held group message", entries)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tbl := []struct {
+		attempts int
+		want     time.Duration
+	}{{1, 5 * time.Second}, {2, 10 * time.Second}, {3, 20 * time.Second}, {10, 2560 * time.Second}, {20, time.Hour}}
+	for _, tt := range tbl {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliveries(t *testing.T) {
+	store := testStore(t)
+	tbl := []struct {
+		name string
+		data string
+		want []delivery
+	}{
+		{name: "list", data: `[{"chat":"@a","message_id":1},{"chat":"@b","topic":2,"message_id":3}]`,
+			want: []delivery{{Chat: "@a", MessageID: 1}, {Chat: "@b", Topic: 2, MessageID: 3}}},
+		{name: "single legacy record", data: `{"chat":"@a","message_id":7,"photo":true}`,
+			want: []delivery{{Chat: "@a", MessageID: 7, Photo: true}}},
+		{name: "broken", data: `{"chat":`},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.DB.Update(func(tx *bolt.Tx) error {
+				bucket, err := tx.CreateBucketIfNotExists([]byte(deliveredBucket))
+				if err != nil {
+					return err
+				}
+				if err = bucket.Put([]byte("key"), []byte(tt.data)); err != nil {
+					return err
+				}
+				got := deliveries(bucket, []byte("key"))
+				if len(got) != len(tt.want) {
+					t.Fatalf("got %+v, want %+v", got, tt.want)
+				}
+				for i := range got {
+					if got[i] != tt.want[i] {
+						t.Errorf("got %+v, want %+v", got[i], tt.want[i])
+					}
+				}
+				if d := deliveries(bucket, []byte("missing")); d != nil {
+					t.Errorf("got %+v for missing key", d)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package proc
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// overridesBucket keeps runtime overrides by feed name
+const overridesBucket = "_overrides"
+
+// Overrides are runtime changes of a feed made with bot commands, applied over its config
+type Overrides struct {
+	Added   []config.Source      `json:"added,omitempty"`
+	Removed []string             `json:"removed,omitempty"` // names of sources from config
+	Muted   map[string]time.Time `json:"muted,omitempty"`   // source name -> not sent to telegram until
+	Filters []string             `json:"filters,omitempty"` // title regexes, matched items are junk
+
+	filters []*regexp.Regexp // compiled Filters on load, nil for bad ones
+}
+
+// Sources returns sources of the feed config without removed ones, followed by added ones
+func (o Overrides) Sources(fm config.Feed) []config.Source {
+	res := make([]config.Source, 0, len(fm.Sources)+len(o.Added))
+	for _, src := range fm.Sources {
+		if !slices.Contains(o.Removed, src.Name) {
+			res = append(res, src)
+		}
+	}
+	return append(res, o.Added...)
+}
+
+// MutedUntil returns time the source is muted until, zero if not muted at the time
+func (o Overrides) MutedUntil(source string, now time.Time) time.Time {
+	if until := o.Muted[source]; until.After(now) {
+		return until
+	}
+	return time.Time{}
+}
+
+// junkReason returns the first filter matching the item's title, empty if none
+func (o Overrides) junkReason(item feed.Item) string {
+	for i, re := range o.filters {
+		if re != nil && re.MatchString(item.Title) {
+			return "bot filter " + o.Filters[i]
+		}
+	}
+	return ""
+}
+
+// compile precompiles filters, bad ones are skipped as they are checked when added
+func (o *Overrides) compile() {
+	o.filters = make([]*regexp.Regexp, len(o.Filters))
+	for i, f := range o.Filters {
+		re, err := regexp.Compile(f)
+		if err != nil {
+			log.Printf("[WARN] bad bot filter %q, %v", f, err)
+			continue
+		}
+		o.filters[i] = re
+	}
+}
+
+// Overrides loads runtime overrides of the feed, empty if none
+func (b BoltDB) Overrides(fmFeed string) (Overrides, error) {
+	res := Overrides{}
+	err := b.DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(overridesBucket))
+		if bucket == nil {
+			return nil
+		}
+		if v := bucket.Get([]byte(fmFeed)); v != nil {
+			return json.Unmarshal(v, &res)
+		}
+		return nil
+	})
+	res.compile()
+	return res, err
+}
+
+// updateOverrides changes runtime overrides of the feed with fn, nothing is saved if fn fails
+func (b BoltDB) updateOverrides(fmFeed string, fn func(o *Overrides) error) error {
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(overridesBucket))
+		if err != nil {
+			return err
+		}
+		o := Overrides{}
+		if v := bucket.Get([]byte(fmFeed)); v != nil {
+			if err = json.Unmarshal(v, &o); err != nil {
+				return err
+			}
+		}
+		if err = fn(&o); err != nil {
+			return err
+		}
+		data, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(fmFeed), data)
+	})
+}
+
+// overrides loads runtime overrides of the feed, logging errors
+func (p *Processor) overrides(fmFeed string) Overrides {
+	o, err := p.Store.Overrides(fmFeed)
+	if err != nil {
+		log.Printf("[WARN] failed to load overrides of %s, %v", fmFeed, err)
+	}
+	return o
+}
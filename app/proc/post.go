@@ -0,0 +1,149 @@
+package proc
+
+import (
+	"context"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/message"
+)
+
+// maxPageSize is a limit of fetched page, og:image is in the head, no need to read more
+const maxPageSize = 512 * 1024
+
+// errPhoto is for failures of the item image, the post is sent as text message instead
+var errPhoto = errors.New("can't send photo")
+
+// photoErrors are parts of telegram errors caused by the photo, not by caption or chat
+var photoErrors = []string{"wrong file identifier", "failed to get HTTP URL content", "wrong type of the web page content",
+	"can't upload file by URL", "wrong remote file", "IMAGE_PROCESS_FAILED", "PHOTO_", "Request Entity Too Large"}
+
+var (
+	reOGImage   = regexp.MustCompile(`(?is)<meta\s[^>]*(?:property|name)\s*=\s*["'](?:og:image|twitter:image)["'][^>]*>`)
+	reOGContent = regexp.MustCompile(`(?is)content\s*=\s*["']([^"']+)["']`)
+)
+
+// ogImage returns og:image (or twitter:image) url of the page, empty if not found
+func ogImage(ctx context.Context, link string) (string, error) {
+	body, err := fetch(ctx, link, maxPageSize)
+	if err != nil {
+		return "", err
+	}
+	meta := reOGImage.Find(body)
+	if meta == nil {
+		return "", nil
+	}
+	m := reOGContent.FindSubmatch(meta)
+	if m == nil {
+		return "", nil
+	}
+	base, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	img, err := base.Parse(html.UnescapeString(strings.TrimSpace(string(m[1]))))
+	if err != nil {
+		return "", errors.Wrap(err, "bad og:image")
+	}
+	return img.String(), nil
+}
+
+// photoURL checks the image url to be passed to telegram, which fetches the image itself
+func photoURL(imgURL string) (string, error) {
+	u, err := url.Parse(imgURL)
+	if err != nil {
+		return "", err
+	}
+	if err = checkScheme(u); err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", errors.New("no host")
+	}
+	return u.String(), nil
+}
+
+// photoRejected checks if telegram failed to send the photo because of the image. Unknown errors are
+// not typed by telebot, so the description is matched.
+func photoRejected(err error) bool {
+	if err == nil {
+		return false
+	}
+	var tbErr *tb.Error
+	if errors.As(err, &tbErr) {
+		return slices.ContainsFunc(photoErrors, func(s string) bool { return strings.Contains(tbErr.Description, s) })
+	}
+	return slices.ContainsFunc(photoErrors, func(s string) bool { return strings.Contains(err.Error(), s) })
+}
+
+// fetch loads up to limit bytes of the page
+func fetch(ctx context.Context, link string, limit int64) ([]byte, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkScheme(u); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := pageClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// keyboard makes inline buttons of the post, buttons without link in the item are skipped
+func keyboard(data message.Data, post config.Post) *tb.ReplyMarkup {
+	var row []tb.InlineButton
+	for _, b := range post.Buttons {
+		switch {
+		case b == config.PostOpen && data.Link != "":
+			row = append(row, tb.InlineButton{Text: "Open", URL: data.Link})
+		case b == config.PostDiscuss && data.Comments != "":
+			row = append(row, tb.InlineButton{Text: "Discussion", URL: data.Comments})
+		case b == config.PostSource && data.SourceLink != "":
+			row = append(row, tb.InlineButton{Text: "Source feed", URL: data.SourceLink})
+		}
+	}
+	if len(row) == 0 {
+		return nil
+	}
+	return &tb.ReplyMarkup{InlineKeyboard: [][]tb.InlineButton{row}}
+}
+
+// previewOptions returns link preview of the post with url other than the first link, nil if not needed
+func previewOptions(data message.Data, post config.Post) *tb.PreviewOptions {
+	if post.NoPreview {
+		return nil
+	}
+	var link string
+	switch post.PreviewURL {
+	case config.PostLink:
+		link = data.Link
+	case config.PostComments:
+		link = data.Comments
+	case config.PostImage:
+		link = data.Image
+	}
+	if link == "" {
+		return nil
+	}
+	return &tb.PreviewOptions{URL: link}
+}
@@ -0,0 +1,61 @@
+package proc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestFetchRejectsInternal(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`<meta property="og:image" content="/img.png">`))
+	}))
+	defer ts.Close()
+
+	for _, link := range []string{ts.URL, "file:///etc/passwd", "gopher://localhost/"} {
+		if _, err := ogImage(context.Background(), link); err == nil {
+			t.Errorf("no error for %s", link)
+		}
+	}
+}
+
+func TestPhotoRejected(t *testing.T) {
+	tbl := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{tb.ErrWrongFileID, true},
+		{tb.ErrBadURLContent, true},
+		{tb.ErrFailedImageProcess, true},
+		{fmt.Errorf("telegram: Bad Request: wrong type of the web page content (400)"), true},
+		{fmt.Errorf("telegram: Bad Request: PHOTO_INVALID_DIMENSIONS (400)"), true},
+		{fmt.Errorf("telegram: Bad Request: can't parse entities: unexpected end tag at byte offset 10 (400)"), false},
+		{fmt.Errorf("telegram: Bad Request: message caption is too long (400)"), false},
+		{tb.ErrChatNotFound, false},
+		{errors.New("network error"), false},
+	}
+	for _, tt := range tbl {
+		if got := photoRejected(tt.err); got != tt.want {
+			t.Errorf("photoRejected(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestPhotoURL(t *testing.T) {
+	tbl := []struct {
+		link string
+		ok   bool
+	}{{"https://example.com/img.png", true}, {"http://example.com/img.png", true}, {"file:///etc/passwd", false},
+		{"/img.png", false}, {"https://", false}}
+	for _, tt := range tbl {
+		if _, err := photoURL(tt.link); (err == nil) != tt.ok {
+			t.Errorf("photoURL(%q) error %v, want ok %v", tt.link, err, tt.ok)
+		}
+	}
+}
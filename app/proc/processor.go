@@ -4,19 +4,47 @@ package proc
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	log "github.com/go-pkgz/lgr"
-	"github.com/go-pkgz/repeater"
 	"github.com/go-pkgz/syncs"
+	bolt "go.etcd.io/bbolt"
 
 	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/expr"
 	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/lang"
+	"github.com/umputun/feed-master/app/message"
+	"github.com/umputun/feed-master/app/pubsub"
 )
 
 // TelegramNotif is interface to send messages to telegram
 type TelegramNotif interface {
-	Send(chanID string, feed feed.Rss2, item feed.Item) error
+	Send(chanID string, tmpl *message.Template, data message.Data, opts SendOptions) (Sent, error)
+	SendText(chanID, text string, opts SendOptions) (msgID int, err error)
+	Edit(chanID string, msgID int, tmpl *message.Template, data message.Data, opts SendOptions) error
+	Delete(chanID string, msgID int) error
+}
+
+// Sent is a telegram message of the item
+type Sent struct {
+	MessageID int
+	Photo     bool // photo with the message as caption
+}
+
+// SendOptions of telegram message
+type SendOptions struct {
+	Silent   bool        // without notification
+	ThreadID int         // forum topic
+	Post     config.Post // rich post of the item, ignored for ready text
+	Photo    bool        // edited message is a photo, its caption is edited
+	Note     string      // appended to edited message
+}
+
+// Publisher is interface to announce newly saved items
+type Publisher interface {
+	Publish(evt pubsub.Event)
 }
 
 // Processor is a feed reader and store writer
@@ -24,11 +52,16 @@ type Processor struct {
 	Conf          *config.Conf
 	Store         *BoltDB
 	TelegramNotif TelegramNotif
+	Events        Publisher    // optional
+	Limiter       *RateLimiter // optional, telegram sends are not limited if nil
+
+	lastFetch sync.Map             // feed name + source url -> time.Time of the last fetch
+	released  map[string]time.Time // feed name -> time of the last message held out of delivery window
 }
 
 // Do activate loop of goroutine for each feed, concurrency limited by p.Conf.Concurrent
 func (p *Processor) Do(ctx context.Context) error {
-	log.Printf("[INFO] activate processor, feeds=%d, %+v", len(p.Conf.Feeds), p.Conf)
+	log.Printf("[INFO] activate processor, feeds=%d, effective limits:\n%s", len(p.Conf.Feeds), p.Conf.LimitsReport())
 
 	for {
 		select {
@@ -43,77 +76,157 @@ func (p *Processor) Do(ctx context.Context) error {
 func (p *Processor) processFeeds(ctx context.Context) {
 	log.Printf("[DEBUG] refresh started")
 
+	// sleep for the shortest update interval, sources with longer intervals are skipped until due
+	tick := p.Conf.System.UpdateInterval
 	swg := syncs.NewSizedGroup(p.Conf.System.Concurrent, syncs.Preemptive, syncs.Context(ctx))
 	for name, fm := range p.Conf.Feeds { //nolint
-		for _, src := range fm.Sources {
+		for _, src := range p.overrides(name).Sources(fm) {
 			name, src, fm := name, src, fm
+			lim := p.Conf.SourceLimits(name, src)
+			if lim.Update < tick {
+				tick = lim.Update
+			}
+			if !p.due(name, src, lim.Update) {
+				continue
+			}
 			swg.Go(func(context.Context) {
-				p.processFeed(name, src.URL, fm.TelegramGroupID, p.Conf.System.MaxItems, fm.Filter)
+				p.processFeed(name, src, fm, lim, false)
 			})
 		}
 	}
 	swg.Wait()
+	p.sendTop(time.Now())
 
 	log.Printf("[DEBUG] refresh completed")
 
-	time.Sleep(p.Conf.System.UpdateInterval)
+	time.Sleep(tick)
+}
+
+// due checks if the source should be fetched now, and records the fetch time if so
+func (p *Processor) due(name string, src config.Source, update time.Duration) bool {
+	key := name + "\x00" + src.URL
+	if last, ok := p.lastFetch.Load(key); ok && time.Since(last.(time.Time))+time.Second < update {
+		return false
+	}
+	p.lastFetch.Store(key, time.Now())
+	return true
 }
 
-func (p *Processor) processFeed(name, url, telegramGroupID string, maxVal int, filter config.Filter) {
-	rss, err := feed.Parse(url)
+// processFeed fetches the source and saves new items, sending them to telegram unless silent.
+// The first successful fetch of a source is silent, except for up to InitialPosts newest items.
+func (p *Processor) processFeed(name string, src config.Source, fm config.Feed, lim config.Limits, silent bool) {
+	rss, err := feed.Parse(src.URL)
 	if err != nil {
-		log.Printf("[WARN] failed to parse %s, %v", url, err)
+		log.Printf("[WARN] failed to parse %s, %v", src.URL, err)
 		return
 	}
 
-	// up to MaxItems (5) items from each feed
-	upto := maxVal
-	if len(rss.ItemList) <= maxVal {
+	// up to MaxPerFetch items from each feed
+	upto := lim.MaxPerFetch
+	if len(rss.ItemList) <= lim.MaxPerFetch {
 		upto = len(rss.ItemList)
 	}
 
+	var initial map[string]bool // items to send on the first fetch, nil if the source is seeded already
+	seeded := p.Store.sourceSeeded(name, src.Name, src.URL)
+	if !seeded {
+		initialPosts := fm.InitialPosts
+		if src.InitialPosts != 0 {
+			initialPosts = src.InitialPosts
+		}
+		if silent {
+			initialPosts = 0
+		}
+		initial = newestGUIDs(rss.ItemList[:upto], initialPosts)
+		log.Printf("[INFO] first fetch of %s in %s, %d items to send", src.Name, name, len(initial))
+	}
+
+	ov := p.overrides(name)
+	subs, err := p.Store.subscribers(name)
+	if err != nil {
+		log.Printf("[WARN] failed to load subscribers of %s, %v", name, err)
+	}
 	for _, item := range rss.ItemList[:upto] { //nolint
-		// skip 1y and older
-		if item.DT.Before(time.Now().AddDate(-1, 0, 0)) {
+		// skip older than MaxAge
+		if item.DT.Before(time.Now().Add(-lim.MaxAge)) {
 			continue
 		}
 
-		skip, err := filter.Skip(item)
-		if err != nil {
-			log.Printf("[WARN] failed to filter %s (%s) to %s, save as is, %v", item.GUID, item.PubDate, name, err)
+		item.Source = src.Name
+		item.Tags = fm.Tags.Match(item)
+		item.Lang = lang.Detect(item.Title + "\n" + string(item.Description))
+		if fm.Scoring.Enabled {
+			item.Score = fm.Scoring.Score(item, time.Now())
+		}
+
+		// feed filter first, then source filter
+		env := expr.Env{Item: item, Feed: name, Source: src.Name, SourceURL: src.URL}
+		reason := fm.Filter.JunkReason(env)
+		if reason == "" {
+			reason = src.Filter.JunkReason(env)
+		}
+		if reason == "" && !fm.LanguageAllowed(item.Lang) {
+			reason = "language " + item.Lang
 		}
-		if skip {
-			item.Junk = true
-			log.Printf("[INFO] filtered %s (%s), %s %s", item.GUID, item.PubDate, name, item.Title)
+		if reason == "" {
+			reason = ov.junkReason(item)
 		}
+		if reason != "" {
+			item.Junk, item.JunkReason = true, reason
+			log.Printf("[INFO] filtered %s (%s), %s %s, %s", item.GUID, item.PubDate, name, item.Title, reason)
+		}
+
+		var checks []SaveCheck
+		if fm.Dedup.Enabled && !item.Junk && !p.Store.exists(name, item) {
+			checks = append(checks, DedupCheck(canonicalLink(resolveLink(context.Background(), item.Link)), fm.Dedup.Window))
+		}
+		if fm.NearDup.Enabled {
+			checks = append(checks, NearDupCheck(*fm.NearDup.Distance, fm.NearDup.Window))
+		}
+		announce := !silent && (initial == nil || initial[item.GUID])
+		checks = append(checks, routeCheck(fm, src, rss, announce, ov, subs))
 
-		created, err := p.Store.Save(name, item)
+		created, err := p.Store.SaveItem(name, &item, checks...)
 		if err != nil {
 			log.Printf("[WARN] failed to save %s (%s) to %s, %v", item.GUID, item.PubDate, name, err)
 		}
 
-		// don't attempt to send anything if the entry was already saved
-		// or in case it was filtered out
-		if !created || item.Junk {
-			continue
+		// title or link of already saved item can be changed, sent messages are edited
+		if err == nil && !created && fm.Updates.Edit {
+			if _, err = p.Store.updateItem(name, item, rss.Title, rss.Link); err != nil {
+				log.Printf("[WARN] failed to update %s in %s, %v", item.GUID, name, err)
+			}
 		}
 
-		rptr := repeater.NewDefault(3, 5*time.Second)
-		err = rptr.Do(context.Background(), func() error {
-			if e := p.TelegramNotif.Send(telegramGroupID, rss, item); e != nil {
-				log.Printf("[WARN] failed attempt to send telegram message, url=%s to channel=%s, %v",
-					item.Enclosure.URL, telegramGroupID, e)
-				return err
-			}
-			return nil
-		})
-		if err != nil {
-			log.Printf("[WARN] failed to send telegram message, to channel=%s, %v", telegramGroupID, err)
+		if created && p.Events != nil {
+			hidden := !announce || item.Junk || item.DuplicateOf != "" || item.SimilarTo != ""
+			p.Events.Publish(pubsub.Event{Feed: name, Item: item, Hidden: hidden})
+		}
+	}
+
+	if fm.Updates.Vanished != "" {
+		if _, err := p.Store.vanished(name, src.Name, rss.ItemList, fm.Updates, time.Now()); err != nil {
+			log.Printf("[WARN] failed to check vanished items of %s in %s, %v", src.Name, name, err)
 		}
 	}
 
-	// keep up to MaxKeepInDB items in bucket
-	if removed, err := p.Store.removeOld(name, p.Conf.System.MaxKeepInDB); err == nil {
+	if !seeded {
+		if err := p.Store.markSeeded(name, src.URL); err != nil {
+			log.Printf("[WARN] failed to mark %s in %s as seeded, %v", src.Name, name, err)
+		}
+	}
+
+	// keep up to MaxKeep items of the source and of the whole feed in bucket
+	if lim.MaxKeep > 0 {
+		if removed, err := p.Store.removeOldSource(name, src.Name, lim.MaxKeep); err == nil {
+			if removed > 0 {
+				log.Printf("[DEBUG] removed %d of %s from %s", removed, src.Name, name)
+			}
+		} else {
+			log.Printf("[WARN] failed to remove, %v", err)
+		}
+	}
+	if removed, err := p.Store.removeOld(name, p.Conf.FeedLimits(name).MaxKeep); err == nil {
 		if removed > 0 {
 			log.Printf("[DEBUG] removed %d from %s", removed, name)
 		}
@@ -121,3 +234,48 @@ func (p *Processor) processFeed(name, url, telegramGroupID string, maxVal int, f
 		log.Printf("[WARN] failed to remove, %v", err)
 	}
 }
+
+// routeCheck is the last check of the new item, routing it to personal subscribers and then to digest, top N
+// candidates or the outbox of the feed destinations, in the save transaction. A saved item is never lost for delivery,
+// it's not saved if routing fails. Items not announced, filtered out, duplicated, of muted source or below
+// the score threshold are not routed.
+func routeCheck(fm config.Feed, src config.Source, rss feed.Rss2, announce bool, ov Overrides,
+	subs map[string]subscription) SaveCheck {
+	return func(tx *bolt.Tx, fmFeed string, _ []byte, item *feed.Item) error {
+		if !announce || item.Junk || item.DuplicateOf != "" || item.SimilarTo != "" {
+			return nil
+		}
+		if until := ov.MutedUntil(src.Name, time.Now()); !until.IsZero() {
+			log.Printf("[DEBUG] %s muted until %s, not sent %s, %s %s", src.Name, until.Format(time.RFC3339), item.GUID,
+				fmFeed, item.Title)
+			return nil
+		}
+		if fm.Scoring.Enabled && fm.Scoring.Threshold != nil && item.Score < *fm.Scoring.Threshold {
+			log.Printf("[DEBUG] score %.2f below threshold, not sent %s, %s %s", item.Score, item.GUID, fmFeed, item.Title)
+			return nil
+		}
+
+		// personal subscribers get items immediately, in any delivery mode of the feed and out of windows
+		if err := fanOutTx(tx, fmFeed, subs, rss, *item); err != nil {
+			return err
+		}
+
+		dsts := fm.Destinations(item.Source)
+		switch {
+		case len(dsts) == 0:
+			return nil
+		case fm.Delivery.Digest():
+			return addDigestTx(tx, fmFeed, *item)
+		case fm.Scoring.Enabled && fm.Scoring.Top > 0:
+			return addTopTx(tx, fmFeed, candidate{Item: *item, SourceTitle: rss.Title, SourceLink: rss.Link}, time.Now())
+		}
+		for _, dst := range dsts {
+			e := outboxEntry{Feed: fmFeed, Chat: dst.Chat, Topic: dst.Topic, Item: *item, SourceTitle: rss.Title,
+				SourceLink: rss.Link}
+			if err := enqueueTx(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+package proc
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiter of telegram sends, with the global budget shared by all chats
+// and a separate budget of each chat. A send takes a token from both.
+type RateLimiter struct {
+	mu       sync.Mutex
+	global   tokenBucket
+	chatRate float64 // tokens per second
+	chatSize float64
+	chats    map[string]*tokenBucket
+}
+
+// RateLimitState is a snapshot of the limiter
+type RateLimitState struct {
+	Global BucketState            `json:"global"`
+	Chats  map[string]BucketState `json:"chats"`
+}
+
+// BucketState is a snapshot of a token bucket, rate is per second
+type BucketState struct {
+	Tokens float64 `json:"tokens"`
+	Size   float64 `json:"size"`
+	Rate   float64 `json:"rate"`
+}
+
+// tokenBucket is refilled at the rate up to the size
+type tokenBucket struct {
+	rate   float64
+	size   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter makes limiter with globalPerSec messages per second overall and chatPerMin messages per minute
+// to each chat, both allowing bursts of the same size
+func NewRateLimiter(globalPerSec, chatPerMin int) *RateLimiter {
+	return &RateLimiter{
+		global:   tokenBucket{rate: float64(globalPerSec), size: float64(globalPerSec), tokens: float64(globalPerSec)},
+		chatRate: float64(chatPerMin) / 60,
+		chatSize: float64(chatPerMin),
+		chats:    map[string]*tokenBucket{},
+	}
+}
+
+// Allow takes a token for the chat if both global and chat budgets have it. Otherwise returns false and
+// time to wait for the global budget, zero if only the chat is out of budget.
+func (l *RateLimiter) Allow(chat string, now time.Time) (ok bool, wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cb, found := l.chats[chat]
+	if !found {
+		cb = &tokenBucket{rate: l.chatRate, size: l.chatSize, tokens: l.chatSize, last: now}
+		l.chats[chat] = cb
+	}
+	l.global.refill(now)
+	cb.refill(now)
+	if l.global.tokens < 1 {
+		return false, l.global.wait()
+	}
+	if cb.tokens < 1 {
+		return false, 0
+	}
+	l.global.tokens--
+	cb.tokens--
+	return true, 0
+}
+
+// Drain empties the chat budget, used when telegram asks to slow down anyway
+func (l *RateLimiter) Drain(chat string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cb, ok := l.chats[chat]; ok {
+		cb.tokens = 0
+	}
+}
+
+// State returns current budgets
+func (l *RateLimiter) State() RateLimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.global.refill(now)
+	res := RateLimitState{Global: l.global.state(), Chats: make(map[string]BucketState, len(l.chats))}
+	for chat, cb := range l.chats {
+		cb.refill(now)
+		res.Chats[chat] = cb.state()
+	}
+	return res
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = min(b.size, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// wait returns time until the next token
+func (b *tokenBucket) wait() time.Duration {
+	if b.tokens >= 1 || b.rate <= 0 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) state() BucketState {
+	return BucketState{Tokens: b.tokens, Size: b.size, Rate: b.rate}
+}
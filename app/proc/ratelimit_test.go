@@ -0,0 +1,70 @@
+package proc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tbl := []struct {
+		name     string
+		global   int
+		perChat  int
+		sends    []string        // chats in order
+		at       []time.Duration // offset of each send from start
+		want     []bool
+		wantWait time.Duration // wait of the last send
+	}{
+		{name: "within budget", global: 10, perChat: 10, sends: []string{"a", "b", "a"},
+			at: []time.Duration{0, 0, 0}, want: []bool{true, true, true}},
+		{name: "global burst exhausted", global: 2, perChat: 10, sends: []string{"a", "b", "c"},
+			at: []time.Duration{0, 0, 0}, want: []bool{true, true, false}, wantWait: 500 * time.Millisecond},
+		{name: "global refilled", global: 2, perChat: 10, sends: []string{"a", "b", "c"},
+			at: []time.Duration{0, 0, time.Second / 2}, want: []bool{true, true, true}},
+		{name: "chat burst exhausted", global: 10, perChat: 2, sends: []string{"a", "a", "a", "b"},
+			at: []time.Duration{0, 0, 0, 0}, want: []bool{true, true, false, true}},
+		{name: "chat refilled", global: 10, perChat: 2, sends: []string{"a", "a", "a"},
+			at: []time.Duration{0, 0, 30 * time.Second}, want: []bool{true, true, true}},
+		{name: "chat not refilled yet", global: 10, perChat: 2, sends: []string{"a", "a", "a"},
+			at: []time.Duration{0, 0, 29 * time.Second}, want: []bool{true, true, false}},
+		{name: "clock going back", global: 1, perChat: 10, sends: []string{"a", "b"},
+			at: []time.Duration{time.Second, 0}, want: []bool{true, false}, wantWait: time.Second},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(tt.global, tt.perChat)
+			var wait time.Duration
+			for i, chat := range tt.sends {
+				var ok bool
+				ok, wait = l.Allow(chat, start.Add(tt.at[i]))
+				if ok != tt.want[i] {
+					t.Fatalf("send %d to %s allowed %v, want %v", i, chat, ok, tt.want[i])
+				}
+			}
+			if wait != tt.wantWait {
+				t.Errorf("wait %s, want %s", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestRateLimiterDrain(t *testing.T) {
+	l := NewRateLimiter(10, 10)
+	now := time.Now()
+	if ok, _ := l.Allow("a", now); !ok {
+		t.Fatal("not allowed")
+	}
+	l.Drain("a")
+	l.Drain("unknown")
+	if ok, wait := l.Allow("a", now); ok || wait != 0 {
+		t.Errorf("drained chat allowed %v, wait %s", ok, wait)
+	}
+	if ok, _ := l.Allow("b", now); !ok {
+		t.Error("other chat not allowed")
+	}
+	state := l.State()
+	if len(state.Chats) != 2 || state.Global.Size != 10 || state.Chats["a"].Tokens >= 1 {
+		t.Errorf("bad state %+v", state)
+	}
+}
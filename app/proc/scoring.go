@@ -0,0 +1,137 @@
+package proc
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// top N buckets, candidates are in nested bucket per feed keyed by item key
+const (
+	topBucket      = "_top"
+	topStartBucket = "_top_start" // feed name to start of the current window
+)
+
+// candidate is an item waiting for top N selection, with the source channel it came from
+type candidate struct {
+	Item        feed.Item `json:"item"`
+	SourceTitle string    `json:"source_title"`
+	SourceLink  string    `json:"source_link,omitempty"`
+}
+
+// sendTop enqueues selected items of all feeds with top N scoring
+func (p *Processor) sendTop(now time.Time) {
+	for name, fm := range p.Conf.Feeds { //nolint
+		if !fm.Scoring.Enabled || fm.Scoring.Top == 0 {
+			continue
+		}
+		if err := p.Store.flushTop(name, fm, now); err != nil {
+			log.Printf("[WARN] failed to select top items of %s, %v", name, err)
+		}
+	}
+}
+
+// addTopTx keeps the candidate of the feed within the transaction, the first candidate starts the window
+func addTopTx(tx *bolt.Tx, fmFeed string, c candidate, now time.Time) error {
+	key, err := itemKey(c.Item)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	root, err := tx.CreateBucketIfNotExists([]byte(topBucket))
+	if err != nil {
+		return err
+	}
+	bucket, err := root.CreateBucketIfNotExists([]byte(fmFeed))
+	if err != nil {
+		return err
+	}
+	if err = bucket.Put(key, data); err != nil {
+		return err
+	}
+
+	starts, err := tx.CreateBucketIfNotExists([]byte(topStartBucket))
+	if err != nil {
+		return err
+	}
+	if starts.Get([]byte(fmFeed)) != nil {
+		return nil
+	}
+	ts, err := now.MarshalText()
+	if err != nil {
+		return err
+	}
+	return starts.Put([]byte(fmFeed), ts)
+}
+
+// flushTop enqueues up to Top best candidates of the feed if its window is closed, and starts a new window.
+// Candidates are removed in the same transaction.
+func (b BoltDB) flushTop(fmFeed string, fm config.Feed, now time.Time) error {
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		starts := tx.Bucket([]byte(topStartBucket))
+		if starts == nil {
+			return nil
+		}
+		v := starts.Get([]byte(fmFeed))
+		if v == nil {
+			return nil
+		}
+		start := time.Time{}
+		if err := start.UnmarshalText(v); err != nil {
+			log.Printf("[WARN] bad start of top window of %s, %v", fmFeed, err)
+		}
+		if now.Sub(start) < fm.Scoring.Window {
+			return nil
+		}
+		if err := starts.Delete([]byte(fmFeed)); err != nil {
+			return err
+		}
+
+		root := tx.Bucket([]byte(topBucket))
+		if root == nil || root.Bucket([]byte(fmFeed)) == nil {
+			return nil
+		}
+		var res []candidate
+		err := root.Bucket([]byte(fmFeed)).ForEach(func(_, v []byte) error {
+			c := candidate{}
+			if e := json.Unmarshal(v, &c); e != nil {
+				log.Printf("[WARN] failed to unmarshal top candidate, %v", e)
+				return nil
+			}
+			res = append(res, c)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err = root.DeleteBucket([]byte(fmFeed)); err != nil {
+			return err
+		}
+
+		sort.SliceStable(res, func(i, j int) bool { return res[i].Item.Score > res[j].Item.Score })
+		if len(res) > fm.Scoring.Top {
+			log.Printf("[INFO] %d of %d candidates selected in %s, min score %.2f", fm.Scoring.Top, len(res), fmFeed,
+				res[fm.Scoring.Top-1].Item.Score)
+			res = res[:fm.Scoring.Top]
+		}
+		for _, c := range res {
+			for _, dst := range fm.Destinations(c.Item.Source) {
+				e := outboxEntry{Feed: fmFeed, Chat: dst.Chat, Topic: dst.Topic, Item: c.Item, SourceTitle: c.SourceTitle,
+					SourceLink: c.SourceLink}
+				if err = enqueueTx(tx, e); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
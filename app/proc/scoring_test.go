@@ -0,0 +1,75 @@
+package proc
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+)
+
+func TestFlushTop(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(dbFile, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &BoltDB{DB: db}
+
+	fm := config.Feed{TelegramGroupID: "@chan", Scoring: config.Scoring{Enabled: true, Top: 2, Window: time.Hour}}
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i, score := range []float64{1, 5, 3} {
+		dt := start.Add(time.Duration(i) * time.Minute)
+		item := feed.Item{GUID: fmt.Sprintf("g%d", i), Title: fmt.Sprintf("item %d", i), DT: dt,
+			PubDate: dt.Format(time.RFC1123Z), Score: score}
+		err = db.Update(func(tx *bolt.Tx) error {
+			return addTopTx(tx, "f1", candidate{Item: item, SourceTitle: "src"}, dt)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = store.flushTop("f1", fm, start.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := store.dueEntries(time.Now()); len(entries) != 0 {
+		t.Fatalf("%d entries enqueued before the window closed", len(entries))
+	}
+
+	// candidates survive restart
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = bolt.Open(dbFile, 0o600, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store = &BoltDB{DB: db}
+
+	if err = store.flushTop("f1", fm, start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := store.dueEntries(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Item.GUID != "g1" || entries[1].Item.GUID != "g2" {
+		t.Fatalf("got %+v, want g1 and g2", entries)
+	}
+	if entries[0].Chat != "@chan" || entries[0].SourceTitle != "src" {
+		t.Errorf("bad entry %+v", entries[0])
+	}
+
+	// the window is started again by the next candidate only
+	if err = store.flushTop("f1", fm, start.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ = store.dueEntries(time.Now()); len(entries) != 2 {
+		t.Errorf("%d entries after empty window, want 2", len(entries))
+	}
+}
@@ -0,0 +1,106 @@
+package proc
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/go-pkgz/syncs"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// sourcesBucket keeps per-source state, key is feed name + source url
+const sourcesBucket = "_sources"
+
+// sourceState is a value of sources bucket
+type sourceState struct {
+	Seeded time.Time `json:"seeded"` // first successful fetch, items of it are saved without notifications
+}
+
+// Seed fetches all sources once and saves items without sending anything, marking sources as seeded
+func (p *Processor) Seed(ctx context.Context) {
+	log.Printf("[INFO] seed all sources")
+	swg := syncs.NewSizedGroup(p.Conf.System.Concurrent, syncs.Preemptive, syncs.Context(ctx))
+	for name, fm := range p.Conf.Feeds { //nolint
+		for _, src := range p.overrides(name).Sources(fm) {
+			name, src, fm := name, src, fm
+			swg.Go(func(context.Context) {
+				p.processFeed(name, src, fm, p.Conf.SourceLimits(name, src), true)
+			})
+		}
+	}
+	swg.Wait()
+	log.Printf("[INFO] seed completed")
+}
+
+// newestGUIDs returns GUIDs of up to n newest items
+func newestGUIDs(items []feed.Item, n int) map[string]bool {
+	sorted := make([]feed.Item, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].DT.After(sorted[j].DT) })
+	res := map[string]bool{}
+	for i := 0; i < n && i < len(sorted); i++ {
+		res[sorted[i].GUID] = true
+	}
+	return res
+}
+
+// sourceSeeded checks if the source had a successful fetch already. Sources with items in the feed
+// saved before seeding was introduced are considered seeded, as well as all sources of the feed with items
+// saved before the source name was recorded in items.
+func (b BoltDB) sourceSeeded(fmFeed, source, url string) bool {
+	seeded := false
+	_ = b.DB.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(sourcesBucket)); bucket != nil {
+			if v := bucket.Get(sourceKey(fmFeed, url)); v != nil {
+				st := sourceState{}
+				seeded = json.Unmarshal(v, &st) == nil && !st.Seeded.IsZero()
+				return nil
+			}
+		}
+		items := tx.Bucket([]byte(fmFeed))
+		if items == nil {
+			return nil
+		}
+		return items.ForEach(func(_, v []byte) error {
+			item := feed.Item{}
+			if json.Unmarshal(v, &item) == nil && (item.Source == source || item.Source == "") {
+				seeded = true
+				return errStop
+			}
+			return nil
+		})
+	})
+	return seeded
+}
+
+// markSeeded records the first successful fetch of the source
+func (b BoltDB) markSeeded(fmFeed, url string) error {
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(sourcesBucket))
+		if err != nil {
+			return err
+		}
+		key := sourceKey(fmFeed, url)
+		st := sourceState{}
+		if v := bucket.Get(key); v != nil {
+			if err = json.Unmarshal(v, &st); err == nil && !st.Seeded.IsZero() {
+				return nil
+			}
+		}
+		st.Seeded = time.Now()
+		data, err := json.Marshal(st)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, data)
+	})
+}
+
+func sourceKey(fmFeed, url string) []byte {
+	return []byte(fmFeed + "\x00" + url)
+}
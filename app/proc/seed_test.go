@@ -0,0 +1,67 @@
+package proc
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/config"
+)
+
+func TestProcessFeedUpgraded(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Blog</title>
+<item><title>new</title><link>https://example.com/new</link><guid>new</guid><pubDate>%s</pubDate></item>
+<item><title>old</title><link>https://example.com/old</link><guid>old</guid><pubDate>%s</pubDate></item>
+</channel></rss>`, now.Format(time.RFC1123Z), now.Add(-time.Hour).Format(time.RFC1123Z))
+	}))
+	defer ts.Close()
+
+	confFile := filepath.Join(t.TempDir(), "fm.yml")
+	conf := fmt.Sprintf("feeds:\n  news:\n    telegram_group_id: \"@news\"\n    sources:\n      - name: blog\n        url: %s\n", ts.URL)
+	if err := os.WriteFile(confFile, []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.Load(confFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the old item stored by a version without sources state and without item's source
+	store := testStore(t)
+	old := testItem("old", now.Add(-time.Hour))
+	key, err := itemKey(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.DB.Update(func(tx *bolt.Tx) error {
+		bucket, e := tx.CreateBucket([]byte("news"))
+		if e != nil {
+			return e
+		}
+		return bucket.Put(key, []byte(fmt.Sprintf(`{"guid":"old","title":"old","link":"https://example.com/old",`+
+			`"pubDate":%q,"dt":%q}`, old.PubDate, old.DT.Format(time.RFC3339))))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Processor{Conf: c, Store: store}
+	src := c.Feeds["news"].Sources[0]
+	p.processFeed("news", src, c.Feeds["news"], c.SourceLimits("news", src), false)
+
+	entries, err := store.dueEntries(now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Item.GUID != "new" {
+		t.Errorf("outbox %+v, want the new item", entries)
+	}
+}
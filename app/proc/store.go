@@ -3,35 +3,41 @@ package proc
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	log "github.com/go-pkgz/lgr"
 	bolt "go.etcd.io/bbolt"
 
 	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/search"
 )
 
+// errStop is returned from bolt ForEach callbacks to stop iteration early
+var errStop = errors.New("stop")
+
 // BoltDB store
 type BoltDB struct {
 	DB *bolt.DB
 }
 
+// SaveCheck is called within the save transaction for a new item before it's stored.
+// It may update the item, i.e. mark it as a duplicate, keep own index data or enqueue the item
+// for delivery in the same transaction. The item is not saved if a check fails.
+type SaveCheck func(tx *bolt.Tx, fmFeed string, key []byte, item *feed.Item) error
+
 // Save to bolt, skip if found
 func (b BoltDB) Save(fmFeed string, item feed.Item) (bool, error) {
+	return b.SaveItem(fmFeed, &item)
+}
+
+// SaveItem saves to bolt, skips if found. Checks are applied to the new item before saving it, in order.
+func (b BoltDB) SaveItem(fmFeed string, item *feed.Item, checks ...SaveCheck) (bool, error) {
 	var created bool
 
-	key, err := func() ([]byte, error) {
-		ts, err := time.Parse(time.RFC1123Z, item.PubDate)
-		if err != nil {
-			return nil, err
-		}
-		h := sha256.New()
-		if _, err = h.Write([]byte(item.GUID)); err != nil {
-			return nil, err
-		}
-		return []byte(fmt.Sprintf("%d-%x", ts.Unix(), h.Sum(nil))), nil
-	}()
+	key, err := itemKey(*item)
 	if err != nil {
 		return created, err
 	}
@@ -45,7 +51,13 @@ func (b BoltDB) Save(fmFeed string, item feed.Item) (bool, error) {
 			return nil
 		}
 
-		jdata, jerr := json.Marshal(&item)
+		for _, check := range checks {
+			if e = check(tx, fmFeed, key, item); e != nil {
+				return e
+			}
+		}
+
+		jdata, jerr := json.Marshal(item)
 		if jerr != nil {
 			return jerr
 		}
@@ -55,6 +67,9 @@ func (b BoltDB) Save(fmFeed string, item feed.Item) (bool, error) {
 		if e != nil {
 			return e
 		}
+		if e = search.Add(tx, fmFeed, key, *item); e != nil {
+			return e
+		}
 
 		created = true
 		return e
@@ -63,6 +78,35 @@ func (b BoltDB) Save(fmFeed string, item feed.Item) (bool, error) {
 	return created, err
 }
 
+// exists checks if item already saved to the feed
+func (b BoltDB) exists(fmFeed string, item feed.Item) bool {
+	key, err := itemKey(item)
+	if err != nil {
+		return false
+	}
+	found := false
+	_ = b.DB.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(fmFeed)); bucket != nil {
+			found = bucket.Get(key) != nil
+		}
+		return nil
+	})
+	return found
+}
+
+// itemKey makes item's key, sortable by publication time
+func itemKey(item feed.Item) ([]byte, error) {
+	ts, err := time.Parse(time.RFC1123Z, item.PubDate)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err = h.Write([]byte(item.GUID)); err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%d-%x", ts.Unix(), h.Sum(nil))), nil
+}
+
 // Load from bold for given feed, up to max
 func (b BoltDB) Load(fmFeed string, maxVal int, skipJunk bool) ([]feed.Item, error) {
 	var result []feed.Item
@@ -92,6 +136,46 @@ func (b BoltDB) Load(fmFeed string, maxVal int, skipJunk bool) ([]feed.Item, err
 	return result, err
 }
 
+// LoadPage loads items for given feed matching the query, newest first.
+// Returns true as the second value if there are more matching items after the page.
+func (b BoltDB) LoadPage(fmFeed string, q feed.Query) ([]feed.Item, bool, error) {
+	var result []feed.Item
+	more := false
+
+	err := b.DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(fmFeed))
+		if bucket == nil {
+			return fmt.Errorf("no bucket for %s", fmFeed)
+		}
+		skipped := 0
+		c := bucket.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			item := feed.Item{}
+			if err := json.Unmarshal(v, &item); err != nil {
+				log.Printf("[WARN] failed to unmarshal, %v", err)
+				continue
+			}
+			if !q.From.IsZero() && item.DT.Before(q.From) {
+				break // items are ordered by time, nothing older can match
+			}
+			if !q.Match(item) {
+				continue
+			}
+			if skipped < q.Offset {
+				skipped++
+				continue
+			}
+			if len(result) >= q.Limit {
+				more = true
+				break
+			}
+			result = append(result, item)
+		}
+		return nil
+	})
+	return result, more, err
+}
+
 func (b BoltDB) removeOld(fmFeed string, keep int) (int, error) {
 	deleted := 0
 	err := b.DB.Update(func(tx *bolt.Tx) error {
@@ -100,18 +184,129 @@ func (b BoltDB) removeOld(fmFeed string, keep int) (int, error) {
 			return fmt.Errorf("no bucket for %s", fmFeed)
 		}
 		recs := 0
+		var keys [][]byte
 		c := bucket.Cursor()
-		var err error
 		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
 			recs++
 			if recs > keep {
-				if e := bucket.Delete(k); e != nil {
-					err = e
-				}
-				deleted++
+				keys = append(keys, append([]byte(nil), k...))
 			}
 		}
-		return err
+		deleted = len(keys)
+		return removeKeys(tx, fmFeed, keys)
 	})
 	return deleted, err
 }
+
+// removeOldSource keeps up to keep items of the source in the feed bucket
+func (b BoltDB) removeOldSource(fmFeed, source string, keep int) (int, error) {
+	deleted := 0
+	err := b.DB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(fmFeed))
+		if bucket == nil {
+			return fmt.Errorf("no bucket for %s", fmFeed)
+		}
+		recs := 0
+		var keys [][]byte
+		c := bucket.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			item := feed.Item{}
+			if err := json.Unmarshal(v, &item); err != nil || item.Source != source {
+				continue
+			}
+			recs++
+			if recs > keep {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+		}
+		deleted = len(keys)
+		return removeKeys(tx, fmFeed, keys)
+	})
+	return deleted, err
+}
+
+// removeKeys deletes items from the feed bucket with all their index data
+func removeKeys(tx *bolt.Tx, fmFeed string, keys [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	bucket := tx.Bucket([]byte(fmFeed))
+	var err error
+	for _, k := range keys {
+		if e := bucket.Delete(k); e != nil {
+			err = e
+		}
+		if e := search.Remove(tx, fmFeed, k); e != nil {
+			err = e
+		}
+		if e := removeSimhash(tx, fmFeed, k); e != nil {
+			err = e
+		}
+		if e := removeDelivered(tx, fmFeed, k); e != nil {
+			err = e
+		}
+		if e := removeDedup(tx, fmFeed, k); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Search items in all feeds, up to q.Limit
+func (b BoltDB) Search(q search.Query) ([]search.Result, error) {
+	var result []search.Result
+
+	err := b.DB.View(func(tx *bolt.Tx) error {
+		for _, hit := range search.Search(tx, q) {
+			bucket := tx.Bucket([]byte(hit.Feed))
+			if bucket == nil {
+				continue
+			}
+			v := bucket.Get(hit.Key)
+			if v == nil {
+				continue
+			}
+			item := feed.Item{}
+			if err := json.Unmarshal(v, &item); err != nil {
+				log.Printf("[WARN] failed to unmarshal, %v", err)
+				continue
+			}
+			result = append(result, search.Result{Feed: hit.Feed, Score: hit.Score, Item: item})
+		}
+		return nil
+	})
+	return result, err
+}
+
+// BuildIndex makes search index from all stored items if the index is missing
+func (b BoltDB) BuildIndex() (int, error) {
+	indexed := 0
+	err := b.DB.Update(func(tx *bolt.Tx) error {
+		if search.Exists(tx) {
+			return nil
+		}
+		if _, err := tx.CreateBucket([]byte(search.RootBucket)); err != nil {
+			return err
+		}
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			if isSystemBucket(string(name)) {
+				return nil
+			}
+			return bucket.ForEach(func(k, v []byte) error {
+				item := feed.Item{}
+				if err := json.Unmarshal(v, &item); err != nil {
+					log.Printf("[WARN] failed to unmarshal, %v", err)
+					return nil
+				}
+				indexed++
+				return search.Add(tx, string(name), k, item)
+			})
+		})
+	})
+	return indexed, err
+}
+
+// isSystemBucket checks if bucket is used internally and not a feed, such buckets are prefixed with "_"
+func isSystemBucket(name string) bool {
+	return strings.HasPrefix(name, "_")
+}
@@ -0,0 +1,70 @@
+package proc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/search"
+)
+
+func TestRemoveOldDropsIndex(t *testing.T) {
+	store := testStore(t)
+	now := time.Now()
+	var keys [][]byte
+	for i, word := range []string{"oldest", "older", "newest"} {
+		item := testItem(word, now.Add(time.Duration(i)*time.Hour))
+		item.Title = "shared " + word
+		if _, err := store.SaveItem("f1", &item); err != nil {
+			t.Fatal(err)
+		}
+		key, err := itemKey(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	removed, err := store.removeOld("f1", 1)
+	if err != nil || removed != 2 {
+		t.Fatalf("removed %d, %v", removed, err)
+	}
+
+	for _, word := range []string{"oldest", "older"} {
+		if res, err := store.Search(search.Query{Terms: []string{word}}); err != nil || len(res) != 0 {
+			t.Errorf("found removed %q: %+v, %v", word, res, err)
+		}
+	}
+	res, err := store.Search(search.Query{Terms: []string{"shared"}})
+	if err != nil || len(res) != 1 || res[0].Item.GUID != "newest" {
+		t.Errorf("search kept %+v, %v", res, err)
+	}
+
+	// no bucket or key of the index refers to removed items, no empty term buckets left
+	err = store.DB.View(func(tx *bolt.Tx) error {
+		var walk func(b *bolt.Bucket, path string)
+		walk = func(b *bolt.Bucket, path string) {
+			if b.Stats().KeyN == 0 {
+				t.Errorf("empty bucket %q", path)
+			}
+			_ = b.ForEach(func(k, v []byte) error {
+				for _, rk := range keys[:2] {
+					if bytes.Contains(k, rk) {
+						t.Errorf("stale %q in %q", k, path)
+					}
+				}
+				if v == nil {
+					walk(b.Bucket(k), path+"/"+string(k))
+				}
+				return nil
+			})
+		}
+		walk(tx.Bucket([]byte(search.RootBucket)), search.RootBucket)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,265 @@
+package proc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/message"
+)
+
+// subscribersBucket keeps personal subscriptions by private chat id
+const subscribersBucket = "_subscribers"
+
+// subscription is a personal subscription to the feed, all items of the feed if no keywords
+type subscription struct {
+	Keywords []string  `json:"keywords,omitempty"` // case-insensitive, any of, in title or description
+	Created  time.Time `json:"created"`
+}
+
+// match checks if the item matches keywords of the subscription
+func (s subscription) match(item feed.Item) bool {
+	if len(s.Keywords) == 0 {
+		return true
+	}
+	text := strings.ToLower(item.Title + " " + string(item.Description))
+	for _, k := range s.Keywords {
+		if strings.Contains(text, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fanOutTx enqueues the item to private chats of subscribers of the feed with matching keywords, within the transaction.
+// It runs before digest, top N and destinations routing on purpose, subscribers get items immediately
+// and delivery windows don't apply to them.
+func fanOutTx(tx *bolt.Tx, fmFeed string, subs map[string]subscription, rss feed.Rss2, item feed.Item) error {
+	for chat, sub := range subs {
+		if !sub.match(item) {
+			continue
+		}
+		e := outboxEntry{Feed: fmFeed, Chat: chat, Item: item, SourceTitle: rss.Title, SourceLink: rss.Link, Subscriber: true}
+		if err := enqueueTx(tx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropSubscriber removes all subscriptions of the chat, for users blocked the bot
+func (p *Processor) dropSubscriber(chat string) {
+	n, err := p.Store.unsubscribe(chat, "")
+	if err != nil {
+		log.Printf("[WARN] failed to unsubscribe %s, %v", chat, err)
+		return
+	}
+	if n > 0 {
+		log.Printf("[INFO] bot blocked by %s, unsubscribed from %d feeds", chat, n)
+	}
+}
+
+// blockedBot checks if the error is forbidden, user blocked the bot or deleted the chat. Telebot returns
+// untyped error for forbidden descriptions it doesn't know, so the text is matched too.
+func blockedBot(err error) bool {
+	if err == nil {
+		return false
+	}
+	var tbErr *tb.Error
+	if errors.As(err, &tbErr) && tbErr.Code == http.StatusForbidden {
+		return true
+	}
+	return strings.Contains(err.Error(), "Forbidden")
+}
+
+// subscribe adds or replaces subscription of the chat to the feed
+func (b BoltDB) subscribe(chat, fmFeed string, keywords []string) error {
+	return b.updateSubscriptions(chat, func(subs map[string]subscription) {
+		subs[fmFeed] = subscription{Keywords: keywords, Created: time.Now()}
+	})
+}
+
+// unsubscribe removes subscription of the chat to the feed, to all feeds if fmFeed is empty.
+// Returns the number of removed subscriptions.
+func (b BoltDB) unsubscribe(chat, fmFeed string) (int, error) {
+	count := 0
+	err := b.updateSubscriptions(chat, func(subs map[string]subscription) {
+		for name := range subs {
+			if fmFeed == "" || name == fmFeed {
+				delete(subs, name)
+				count++
+			}
+		}
+	})
+	return count, err
+}
+
+// updateSubscriptions changes subscriptions of the chat with fn, the chat is removed if none left
+func (b BoltDB) updateSubscriptions(chat string, fn func(subs map[string]subscription)) error {
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(subscribersBucket))
+		if err != nil {
+			return err
+		}
+		subs := map[string]subscription{}
+		if v := bucket.Get([]byte(chat)); v != nil {
+			if err = json.Unmarshal(v, &subs); err != nil {
+				return err
+			}
+		}
+		fn(subs)
+		if len(subs) == 0 {
+			return bucket.Delete([]byte(chat))
+		}
+		data, err := json.Marshal(subs)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(chat), data)
+	})
+}
+
+// subscriptions returns subscriptions of the chat by feed name
+func (b BoltDB) subscriptions(chat string) (map[string]subscription, error) {
+	subs := map[string]subscription{}
+	err := b.DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(subscribersBucket))
+		if bucket == nil {
+			return nil
+		}
+		if v := bucket.Get([]byte(chat)); v != nil {
+			return json.Unmarshal(v, &subs)
+		}
+		return nil
+	})
+	return subs, err
+}
+
+// subscribers returns subscriptions to the feed by chat
+func (b BoltDB) subscribers(fmFeed string) (map[string]subscription, error) {
+	res := map[string]subscription{}
+	err := b.DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(subscribersBucket))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			subs := map[string]subscription{}
+			if err := json.Unmarshal(v, &subs); err != nil {
+				log.Printf("[WARN] failed to unmarshal subscriptions of %s, %v", k, err)
+				return nil
+			}
+			if sub, ok := subs[fmFeed]; ok {
+				res[string(k)] = sub
+			}
+			return nil
+		})
+	})
+	return res, err
+}
+
+// private allows the command in private chat with the bot only
+func (cmd Commands) private(h func(tb.Context, []string) (string, error)) func(tb.Context, []string) (string, error) {
+	return func(c tb.Context, args []string) (string, error) {
+		if c.Chat().Type != tb.ChatPrivate {
+			return "", errors.New("send it to me in private chat")
+		}
+		return h(c, args)
+	}
+}
+
+// /start - help of personal subscriptions
+func (cmd Commands) start(_ tb.Context, _ []string) (string, error) {
+	return "/subscribe &lt;feed&gt; [--keyword word ...] - items of the feed, with any of keywords only if set\n" +
+		"/unsubscribe [feed] - from the feed, from all feeds if not set\n" +
+		"/list - your subscriptions and available feeds", nil
+}
+
+// /subscribe <feed> [--keyword word ...] - subscribes the private chat to the feed
+func (cmd Commands) subscribe(c tb.Context, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("usage: /subscribe <feed> [--keyword word ...]")
+	}
+	name := args[0]
+	if _, ok := cmd.Processor.Conf.Feeds[name]; !ok {
+		return "", errors.Errorf("no feed %q, one of %s", name, strings.Join(cmd.feedNames(), ", "))
+	}
+	var keywords []string
+	for i := 1; i < len(args); i++ {
+		switch {
+		case args[i] == "--keyword" && i+1 < len(args):
+			keywords = append(keywords, args[i+1])
+			i++
+		case strings.HasPrefix(args[i], "--keyword="):
+			keywords = append(keywords, strings.TrimPrefix(args[i], "--keyword="))
+		default:
+			return "", errors.Errorf("unexpected %q, usage: /subscribe <feed> [--keyword word ...]", args[i])
+		}
+	}
+
+	if err := cmd.Processor.Store.subscribe(fmt.Sprint(c.Chat().ID), name, keywords); err != nil {
+		return "", errors.Wrap(err, "can't subscribe")
+	}
+	log.Printf("[INFO] %d subscribed to %s, keywords %v", c.Chat().ID, name, keywords)
+	if len(keywords) > 0 {
+		return fmt.Sprintf("subscribed to %s, items with %s", message.Escape(name),
+			message.Escape(strings.Join(keywords, ", "))), nil
+	}
+	return fmt.Sprintf("subscribed to %s", message.Escape(name)), nil
+}
+
+// /unsubscribe [feed] - unsubscribes the private chat from the feed, from all feeds if not set
+func (cmd Commands) unsubscribe(c tb.Context, args []string) (string, error) {
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	n, err := cmd.Processor.Store.unsubscribe(fmt.Sprint(c.Chat().ID), name)
+	if err != nil {
+		return "", errors.Wrap(err, "can't unsubscribe")
+	}
+	if n == 0 {
+		return "no such subscriptions", nil
+	}
+	log.Printf("[INFO] %d unsubscribed from %d feeds", c.Chat().ID, n)
+	return fmt.Sprintf("unsubscribed from %d feeds", n), nil
+}
+
+// /list - subscriptions of the private chat and feeds available
+func (cmd Commands) list(c tb.Context, _ []string) (string, error) {
+	subs, err := cmd.Processor.Store.subscriptions(fmt.Sprint(c.Chat().ID))
+	if err != nil {
+		return "", errors.Wrap(err, "can't load subscriptions")
+	}
+	var lines []string
+	for _, name := range cmd.feedNames() {
+		line := fmt.Sprintf("%s %s", message.Escape(name), message.Escape(cmd.Processor.Conf.Feeds[name].Title))
+		if sub, ok := subs[name]; ok {
+			line = "<b>" + line + "</b>, subscribed"
+			if len(sub.Keywords) > 0 {
+				line += ", " + message.Escape(strings.Join(sub.Keywords, ", "))
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// feedNames returns sorted names of all feeds
+func (cmd Commands) feedNames() []string {
+	res := make([]string, 0, len(cmd.Processor.Conf.Feeds))
+	for name := range cmd.Processor.Conf.Feeds { //nolint
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,61 @@
+package proc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/umputun/feed-master/app/config"
+)
+
+func TestBlockedBot(t *testing.T) {
+	tbl := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{tb.ErrBlockedByUser, true},
+		{tb.ErrKickedFromGroup, true},
+		{fmt.Errorf("telegram: Forbidden: user is deactivated (403)"), true},
+		{fmt.Errorf("can't send: %w", fmt.Errorf("telegram: Forbidden: bot can't initiate conversation with a user (403)")), true},
+		{tb.ErrChatNotFound, false},
+		{errors.New("network error"), false},
+	}
+	for _, tt := range tbl {
+		if got := blockedBot(tt.err); got != tt.want {
+			t.Errorf("blockedBot(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverDropsBlockedSubscriber(t *testing.T) {
+	store := testStore(t)
+	notif := &notifMock{errs: map[string]error{"42": fmt.Errorf("telegram: Forbidden: user is deactivated (403)")}}
+	p := &Processor{Conf: &config.Conf{Feeds: map[string]config.Feed{"f1": {}}}, Store: store, TelegramNotif: notif}
+	if err := store.subscribe("42", "f1", nil); err != nil {
+		t.Fatal(err)
+	}
+	err := store.DB.Update(func(tx *bolt.Tx) error {
+		return enqueueTx(tx, outboxEntry{Feed: "f1", Chat: "42", Item: testItem("a1", time.Now()), Subscriber: true})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.deliverDue(context.Background())
+	subs, err := store.subscribers("f1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 0 {
+		t.Errorf("blocked subscriber kept, %+v", subs)
+	}
+	if stats, _ := store.OutboxStats(); stats.Pending != 0 || stats.Dead != 1 {
+		t.Errorf("stats %+v, want entry moved to dead letters", stats)
+	}
+}
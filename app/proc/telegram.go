@@ -1,6 +1,8 @@
 package proc
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -11,7 +13,7 @@ import (
 	"github.com/pkg/errors"
 	tb "gopkg.in/telebot.v3"
 
-	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/message"
 )
 
 // TelegramClient client
@@ -52,12 +54,12 @@ func NewTelegramClient(token, apiURL string, timeout time.Duration, tgs Telegram
 
 	bot.Handle("/chat_id", func(c tb.Context) error {
 		chatID := fmt.Sprintf("%d", c.Chat().ID)
-
+		if msg := c.Message(); msg != nil && msg.TopicMessage {
+			return c.Send(fmt.Sprintf("%s, topic %d", chatID, msg.ThreadID), &tb.SendOptions{ThreadID: msg.ThreadID})
+		}
 		return c.Send(chatID)
 	})
 
-	go bot.Start()
-
 	result := TelegramClient{
 		Bot:            bot,
 		Timeout:        timeout,
@@ -66,37 +68,196 @@ func NewTelegramClient(token, apiURL string, timeout time.Duration, tgs Telegram
 	return &result, err
 }
 
-// Send message, skip if telegram token empty
-func (client TelegramClient) Send(channelID string, rssFeed feed.Rss2, item feed.Item) (err error) {
+// Start polls for bot commands in background, handlers of additional commands are added before the start
+func (client TelegramClient) Start() {
+	if client.Bot != nil {
+		go client.Bot.Start()
+	}
+}
+
+// Send message of the item made with the template, returns id of the sent message. Skip if telegram token empty.
+// With photo posts the item image is sent with the message as caption, text message is sent instead
+// if the image can't be found, fetched or is rejected by telegram.
+func (client TelegramClient) Send(channelID string, tmpl *message.Template, data message.Data, opts SendOptions) (Sent, error) {
+	if client.Bot == nil || channelID == "" {
+		return Sent{}, nil
+	}
+
+	if opts.Post.Photo {
+		msg, err := client.sendPhoto(channelID, tmpl, data, opts)
+		if err == nil {
+			log.Printf("[DEBUG] telegram photo sent: \n%s", msg.Caption)
+			return Sent{MessageID: msg.ID, Photo: true}, nil
+		}
+		if !errors.Is(err, errPhoto) {
+			return Sent{}, errors.Wrapf(err, "can't send photo to telegram for %s", data.Link)
+		}
+		log.Printf("[DEBUG] %v, sending text of %s", err, data.Link)
+	}
+
+	msg, err := client.sendText(channelID, tmpl, data, opts)
+	if err != nil {
+		return Sent{}, errors.Wrapf(err, "can't send to telegram for %+v", data.Enclosure)
+	}
+
+	log.Printf("[DEBUG] telegram message sent: \n%s", msg.Text)
+	return Sent{MessageID: msg.ID}, nil
+}
+
+// Edit updates text, or caption of a photo, of the sent message of the item, keeping its buttons.
+// The note is appended if set. Unchanged message is not an error.
+func (client TelegramClient) Edit(channelID string, msgID int, tmpl *message.Template, data message.Data, opts SendOptions) error {
 	if client.Bot == nil || channelID == "" {
 		return nil
 	}
 
-	message, err := client.sendText(channelID, rssFeed, item)
+	method, field, maxLen, render := "editMessageText", "text", message.MaxLength, tmpl.Render
+	if opts.Photo {
+		method, field, maxLen, render = "editMessageCaption", "caption", message.MaxCaptionLength, tmpl.RenderCaption
+	}
+	text, err := render(data)
 	if err != nil {
-		return errors.Wrapf(err, "can't send to telegram for %+v", item.Enclosure)
+		return errors.Wrap(err, "can't render message")
+	}
+	if opts.Note != "" {
+		text = message.Append(text, opts.Note, maxLen)
 	}
 
-	log.Printf("[DEBUG] telegram message sent: \n%s", message.Text)
+	// edits are made directly, telebot's edits need numeric chat id
+	params := map[string]any{
+		"chat_id":    recipient{chatID: channelID}.Recipient(),
+		"message_id": msgID,
+		field:        text,
+		"parse_mode": tb.ModeHTML,
+	}
+	if kb := keyboard(data, opts.Post); kb != nil {
+		params["reply_markup"] = kb
+	}
+	if !opts.Photo {
+		if preview := previewOptions(data, opts.Post); preview != nil {
+			params["link_preview_options"] = preview
+		}
+		if opts.Post.NoPreview {
+			params["link_preview_options"] = tb.PreviewOptions{Disabled: true}
+		}
+	}
+	_, err = client.Bot.Raw(method, params)
+	if errors.Is(err, tb.ErrSameMessageContent) || errors.Is(err, tb.ErrMessageNotModified) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "can't edit message %d in %s", msgID, channelID)
+	}
+	log.Printf("[DEBUG] telegram message %d in %s edited: \n%s", msgID, channelID, text)
 	return nil
 }
 
-func (client TelegramClient) sendText(channelID string, rssFeed feed.Rss2, item feed.Item) (*tb.Message, error) {
-	message, err := client.Bot.Send(
-		recipient{chatID: channelID},
-		client.getMessageHTML(rssFeed, item),
-		tb.ModeHTML,
-		// tb.NoPreview,
-	)
+// Delete removes the sent message, already removed message is not an error
+func (client TelegramClient) Delete(channelID string, msgID int) error {
+	if client.Bot == nil || channelID == "" {
+		return nil
+	}
+	params := map[string]any{"chat_id": recipient{chatID: channelID}.Recipient(), "message_id": msgID}
+	if _, err := client.Bot.Raw("deleteMessage", params); err != nil && !errors.Is(err, tb.ErrNotFoundToDelete) {
+		return errors.Wrapf(err, "can't delete message %d in %s", msgID, channelID)
+	}
+	return nil
+}
 
-	return message, err
+// SendText sends ready HTML message, skip if telegram token empty
+func (client TelegramClient) SendText(channelID, text string, opts SendOptions) (msgID int, err error) {
+	if client.Bot == nil || channelID == "" {
+		return 0, nil
+	}
+	msg, err := client.sendHTML(channelID, text, client.sendOptions(message.Data{}, opts), nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "can't send to telegram")
+	}
+	return msg.ID, nil
+}
+
+func (client TelegramClient) sendText(channelID string, tmpl *message.Template, data message.Data, opts SendOptions) (*tb.Message, error) {
+	text, err := tmpl.Render(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't render message")
+	}
+	return client.sendHTML(channelID, text, client.sendOptions(data, opts), previewOptions(data, opts.Post))
+}
+
+// sendPhoto sends the item image with caption, errors of the image are errPhoto
+func (client TelegramClient) sendPhoto(channelID string, tmpl *message.Template, data message.Data, opts SendOptions) (*tb.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), client.Timeout)
+	defer cancel()
+
+	imgURL := data.Image
+	if imgURL == "" && opts.Post.OGImage && data.Link != "" {
+		var err error
+		if imgURL, err = ogImage(ctx, data.Link); err != nil {
+			return nil, errors.Wrapf(errPhoto, "og:image of %s, %v", data.Link, err)
+		}
+	}
+	if imgURL == "" {
+		return nil, errors.Wrap(errPhoto, "no image")
+	}
+	photoLink, err := photoURL(imgURL)
+	if err != nil {
+		return nil, errors.Wrapf(errPhoto, "image %s, %v", imgURL, err)
+	}
+
+	caption, err := tmpl.RenderCaption(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't render caption")
+	}
+	photo := &tb.Photo{File: tb.FromURL(photoLink), Caption: caption}
+	msg, err := client.Bot.Send(recipient{chatID: channelID}, photo, client.sendOptions(data, opts))
+	if photoRejected(err) {
+		return nil, errors.Wrapf(errPhoto, "image %s rejected, %v", imgURL, err)
+	}
+	return msg, err
+}
+
+// sendOptions makes telegram options of the message, with buttons of the item if any
+func (client TelegramClient) sendOptions(data message.Data, opts SendOptions) *tb.SendOptions {
+	return &tb.SendOptions{
+		ParseMode:             tb.ModeHTML,
+		DisableNotification:   opts.Silent,
+		ThreadID:              opts.ThreadID,
+		DisableWebPagePreview: opts.Post.NoPreview,
+		ReplyMarkup:           keyboard(data, opts.Post),
+	}
 }
 
-// getMessageHTML generates HTML message from provided feed.Item
-func (client TelegramClient) getMessageHTML(_ feed.Rss2, item feed.Item) string {
-	title := strings.TrimSpace(item.Title)
+// sendHTML sends the text message, with link preview of the given url if preview is set
+func (client TelegramClient) sendHTML(channelID, text string, opts *tb.SendOptions, preview *tb.PreviewOptions) (*tb.Message, error) {
+	if preview == nil {
+		return client.Bot.Send(recipient{chatID: channelID}, text, opts)
+	}
 
-	return fmt.Sprintf(`<a href="%s"><b>%s</b></a>`, item.Link, title)
+	// telebot's send options have no link preview options, so the request is made directly
+	params := map[string]any{
+		"chat_id":              recipient{chatID: channelID}.Recipient(),
+		"text":                 text,
+		"parse_mode":           opts.ParseMode,
+		"disable_notification": opts.DisableNotification,
+		"link_preview_options": preview,
+	}
+	if opts.ThreadID != 0 {
+		params["message_thread_id"] = opts.ThreadID
+	}
+	if opts.ReplyMarkup != nil {
+		params["reply_markup"] = opts.ReplyMarkup
+	}
+	resp, err := client.Bot.Raw("sendMessage", params)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Result *tb.Message `json:"result"`
+	}
+	if err = json.Unmarshal(resp, &res); err != nil || res.Result == nil {
+		return nil, errors.Errorf("can't decode sent message, %v", err)
+	}
+	return res.Result, nil
 }
 
 type recipient struct {
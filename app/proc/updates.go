@@ -0,0 +1,194 @@
+package proc
+
+import (
+	"encoding/json"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/search"
+)
+
+// outbox entry actions on sent messages
+const (
+	actionEdit   = "edit"
+	actionDelete = "delete"
+)
+
+// vanishedNote is appended to annotated posts of vanished items
+const vanishedNote = "<i>removed from the source</i>"
+
+// updateItem saves changed title and link of the stored item and enqueues edits of its sent messages.
+// Returns false if the item is unchanged or not stored. Called for every fetched item, so changes are looked
+// for in a read transaction first.
+func (b BoltDB) updateItem(fmFeed string, item feed.Item, sourceTitle, sourceLink string) (bool, error) {
+	key, err := itemKey(item)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	err = b.DB.View(func(tx *bolt.Tx) error {
+		stored, e := storedItem(tx, fmFeed, key)
+		changed = e == nil && stored != nil && (stored.Title != item.Title || stored.Link != item.Link)
+		return e
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+
+	changed = false
+	err = b.DB.Update(func(tx *bolt.Tx) error {
+		stored, e := storedItem(tx, fmFeed, key)
+		if e != nil || stored == nil || (stored.Title == item.Title && stored.Link == item.Link) {
+			return e
+		}
+		bucket := tx.Bucket([]byte(fmFeed))
+
+		log.Printf("[INFO] changed %s in %s, %q %s -> %q %s", item.GUID, fmFeed, stored.Title, stored.Link, item.Title, item.Link)
+		stored.Title, stored.Link = item.Title, item.Link
+		data, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+		if err = bucket.Put(key, data); err != nil {
+			return err
+		}
+		if err = search.Remove(tx, fmFeed, key); err != nil {
+			return err
+		}
+		if err = search.Add(tx, fmFeed, key, *stored); err != nil {
+			return err
+		}
+		changed = true
+
+		root := tx.Bucket([]byte(deliveredBucket))
+		if root == nil || root.Bucket([]byte(fmFeed)) == nil {
+			return nil
+		}
+		for _, d := range deliveries(root.Bucket([]byte(fmFeed)), key) {
+			if d.Vanished {
+				continue
+			}
+			e := outboxEntry{Feed: fmFeed, Chat: d.Chat, Topic: d.Topic, Item: *stored, SourceTitle: sourceTitle,
+				SourceLink: sourceLink, Action: actionEdit, MessageID: d.MessageID, Photo: d.Photo}
+			if err = enqueueTx(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// storedItem loads the item from the feed bucket, nil if not stored
+func storedItem(tx *bolt.Tx, fmFeed string, key []byte) (*feed.Item, error) {
+	bucket := tx.Bucket([]byte(fmFeed))
+	if bucket == nil {
+		return nil, nil
+	}
+	v := bucket.Get(key)
+	if v == nil {
+		return nil, nil
+	}
+	res := feed.Item{}
+	if err := json.Unmarshal(v, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// vanished enqueues deletes or annotations of messages sent within grace period for items of the source
+// missing in its fetched items. Items older than the oldest fetched one are considered fallen off the feed,
+// not removed. Returns the number of vanished items.
+func (b BoltDB) vanished(fmFeed, source string, fetched []feed.Item, u config.Updates, now time.Time) (int, error) {
+	if len(fetched) == 0 {
+		return 0, nil // broken or empty source, nothing to compare with
+	}
+	guids := make(map[string]bool, len(fetched))
+	oldest := fetched[0].DT
+	for _, item := range fetched {
+		guids[item.GUID] = true
+		if item.DT.Before(oldest) {
+			oldest = item.DT
+		}
+	}
+
+	count := 0
+	err := b.DB.Update(func(tx *bolt.Tx) error {
+		root, items := tx.Bucket([]byte(deliveredBucket)), tx.Bucket([]byte(fmFeed))
+		if root == nil || root.Bucket([]byte(fmFeed)) == nil || items == nil {
+			return nil
+		}
+		bucket := root.Bucket([]byte(fmFeed))
+
+		type found struct {
+			key []byte
+			ds  []delivery
+		}
+		var res []found
+		err := bucket.ForEach(func(k, _ []byte) error {
+			ds := deliveries(bucket, k)
+			if !vanishCandidate(ds, now.Add(-u.Grace)) {
+				return nil
+			}
+			v := items.Get(k)
+			if v == nil {
+				return nil
+			}
+			item := feed.Item{}
+			if err := json.Unmarshal(v, &item); err != nil {
+				log.Printf("[WARN] failed to unmarshal %s, %v", k, err)
+				return nil
+			}
+			if item.Source != source || guids[item.GUID] || item.DT.Before(oldest) {
+				return nil
+			}
+			log.Printf("[INFO] vanished %s from %s in %s, %s", item.GUID, source, fmFeed, item.Title)
+			res = append(res, found{key: append([]byte(nil), k...), ds: ds})
+
+			for _, d := range ds {
+				if d.Vanished {
+					continue
+				}
+				e := outboxEntry{Feed: fmFeed, Chat: d.Chat, Topic: d.Topic, Item: item, Action: actionDelete,
+					MessageID: d.MessageID}
+				if u.Vanished == config.VanishedAnnotate {
+					e.Action, e.Photo, e.Note = actionEdit, d.Photo, vanishedNote
+				}
+				if err := enqueueTx(tx, e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// mark deliveries after iteration, bucket can't be modified in ForEach
+		for _, f := range res {
+			for i := range f.ds {
+				f.ds[i].Vanished = true
+			}
+			if err = putDeliveries(bucket, f.key, f.ds); err != nil {
+				return err
+			}
+		}
+		count = len(res)
+		return nil
+	})
+	return count, err
+}
+
+// vanishCandidate checks if some of deliveries sent after since are not marked as vanished
+func vanishCandidate(ds []delivery, since time.Time) bool {
+	for _, d := range ds {
+		if !d.Vanished && d.Sent.After(since) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package proc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/umputun/feed-master/app/config"
+	"github.com/umputun/feed-master/app/feed"
+	"github.com/umputun/feed-master/app/search"
+)
+
+// deliveredItem saves the item and records it as sent to chat -100 as the message
+func deliveredItem(t *testing.T, store *BoltDB, item feed.Item, msg Sent) {
+	t.Helper()
+	if _, err := store.SaveItem("f1", &item); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.markDelivered(outboxEntry{Feed: "f1", Chat: "-100", Item: item}, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateItem(t *testing.T) {
+	store := testStore(t)
+	now := time.Now()
+	item := testItem("a", now.Add(-time.Hour))
+	deliveredItem(t, store, item, Sent{MessageID: 7, Photo: true})
+
+	outbox := func() []outboxEntry {
+		entries, err := store.dueEntries(now.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+
+	changed, err := store.updateItem("f1", item, "src", "https://example.com")
+	if err != nil || changed || len(outbox()) != 0 {
+		t.Fatalf("unchanged item updated %v, %v, outbox %+v", changed, err, outbox())
+	}
+	if changed, err = store.updateItem("f1", testItem("b", now), "src", ""); err != nil || changed {
+		t.Fatalf("not stored item updated %v, %v", changed, err)
+	}
+
+	item.Title, item.Link = "fixed title", "https://example.com/fixed"
+	if changed, err = store.updateItem("f1", item, "src", "https://example.com"); err != nil || !changed {
+		t.Fatalf("changed item not updated %v, %v", changed, err)
+	}
+	entries := outbox()
+	if len(entries) != 1 {
+		t.Fatalf("outbox %+v, want one edit", entries)
+	}
+	e := entries[0]
+	if e.Action != actionEdit || e.Chat != "-100" || e.MessageID != 7 || !e.Photo || e.Item.Title != "fixed title" ||
+		e.Item.Link != "https://example.com/fixed" || e.SourceTitle != "src" {
+		t.Errorf("bad edit %+v", e)
+	}
+	stored, err := store.Load("f1", 10, false)
+	if err != nil || len(stored) != 1 || stored[0].Title != "fixed title" || stored[0].Link != "https://example.com/fixed" {
+		t.Errorf("stored %+v, %v", stored, err)
+	}
+	if res, err := store.Search(search.Query{Terms: []string{"fixed"}}); err != nil || len(res) != 1 {
+		t.Errorf("search by new title %+v, %v", res, err)
+	}
+
+	if changed, err = store.updateItem("f1", item, "src", ""); err != nil || changed || len(outbox()) != 1 {
+		t.Errorf("updated again %v, %v", changed, err)
+	}
+}
+
+func TestVanished(t *testing.T) {
+	tbl := []struct {
+		policy string
+		want   outboxEntry
+	}{
+		{config.VanishedDelete, outboxEntry{Action: actionDelete, MessageID: 7}},
+		{config.VanishedAnnotate, outboxEntry{Action: actionEdit, MessageID: 7, Photo: true, Note: vanishedNote}},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.policy, func(t *testing.T) {
+			store := testStore(t)
+			now := time.Now()
+			oldest, gone, kept, other := testItem("oldest", now.Add(-3*time.Hour)), testItem("gone", now.Add(-2*time.Hour)),
+				testItem("kept", now.Add(-time.Hour)), testItem("other", now.Add(-time.Hour))
+			for _, item := range []feed.Item{oldest, gone, kept, other} {
+				item.Source = "s1"
+				if item.GUID == "other" {
+					item.Source = "s2"
+				}
+				msgID := 1
+				if item.GUID == "gone" {
+					msgID = 7
+				}
+				deliveredItem(t, store, item, Sent{MessageID: msgID, Photo: true})
+			}
+			// older items fell off the feed, not vanished
+			fetched := []feed.Item{kept, testItem("older", now.Add(-150*time.Minute))}
+			u := config.Updates{Vanished: tt.policy, Grace: 24 * time.Hour}
+
+			count, err := store.vanished("f1", "s1", fetched, u, now.Add(48*time.Hour))
+			if err != nil || count != 0 {
+				t.Fatalf("vanished after grace %d, %v", count, err)
+			}
+			count, err = store.vanished("f1", "s1", fetched, u, now)
+			if err != nil || count != 1 {
+				t.Fatalf("vanished %d, %v", count, err)
+			}
+			entries, err := store.dueEntries(now.Add(time.Hour))
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("outbox %+v, %v", entries, err)
+			}
+			e := entries[0]
+			if e.Item.GUID != "gone" || e.Chat != "-100" || e.Action != tt.want.Action || e.MessageID != tt.want.MessageID ||
+				e.Photo != tt.want.Photo || e.Note != tt.want.Note {
+				t.Errorf("got %+v, want %+v", e, tt.want)
+			}
+
+			if count, err = store.vanished("f1", "s1", fetched, u, now); err != nil || count != 0 {
+				t.Errorf("vanished again %d, %v", count, err)
+			}
+		})
+	}
+}
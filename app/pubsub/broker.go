@@ -0,0 +1,92 @@
+// Package pubsub provides in-process publish/subscribe for new items.
+// Publishing never blocks, subscribers too slow to keep up with their buffer are disconnected.
+package pubsub
+
+import (
+	"sync"
+
+	log "github.com/go-pkgz/lgr"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// Event is published for each new item saved
+type Event struct {
+	Feed   string    `json:"feed"`
+	Item   feed.Item `json:"item"`
+	Hidden bool      `json:"-"` // saved only, not announced: junk, duplicate or silently seeded
+}
+
+// Broker dispatches events to subscribers
+type Broker struct {
+	bufSize int
+
+	lock sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives events for a single feed or all feeds if feed is empty.
+// C is closed on Unsubscribe or if the subscriber was disconnected as a slow one.
+type Subscription struct {
+	C    <-chan Event
+	ch   chan Event
+	feed string
+}
+
+// NewBroker makes broker with buffer of bufSize events per subscriber
+func NewBroker(bufSize int) *Broker {
+	if bufSize <= 0 {
+		bufSize = 1
+	}
+	return &Broker{bufSize: bufSize, subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe to events of the feed, all feeds if fmFeed is empty
+func (b *Broker) Subscribe(fmFeed string) *Subscription {
+	ch := make(chan Event, b.bufSize)
+	sub := &Subscription{C: ch, ch: ch, feed: fmFeed}
+	b.lock.Lock()
+	b.subs[sub] = struct{}{}
+	b.lock.Unlock()
+	return sub
+}
+
+// Unsubscribe removes subscription and closes its channel, safe to call multiple times
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.remove(sub)
+}
+
+// Publish sends event to all matching subscribers without blocking.
+// Subscriber with the full buffer is disconnected, it is expected to reconnect and reload.
+func (b *Broker) Publish(evt Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for sub := range b.subs {
+		if sub.feed != "" && sub.feed != evt.Feed {
+			continue
+		}
+		select {
+		case sub.ch <- evt:
+		default:
+			log.Printf("[DEBUG] slow subscriber for %q disconnected", sub.feed)
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribers returns number of active subscriptions
+func (b *Broker) Subscribers() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.subs)
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	close(sub.ch)
+}
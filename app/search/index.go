@@ -0,0 +1,181 @@
+// Package search provides embedded full-text index of stored feed items.
+// The index is kept in the same bolt db as items and updated within the same transactions.
+package search
+
+import (
+	"bytes"
+	"encoding/binary"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// RootBucket is a top-level bucket for the index, all index buckets are nested
+const RootBucket = "_search"
+
+const (
+	termsBucket = "terms" // term -> {docID -> positions}
+	docsBucket  = "docs"  // docID -> list of terms, used for removal
+)
+
+// field weights, field is encoded in the high bits of the position
+const fieldShift = 20
+
+type field uint64
+
+const (
+	fieldTitle field = iota + 1
+	fieldAuthor
+	fieldSource
+	fieldDescription
+	fieldContent
+)
+
+var fieldWeight = map[field]int{fieldTitle: 3, fieldAuthor: 2, fieldSource: 2, fieldDescription: 1, fieldContent: 1}
+
+var reTags = regexp.MustCompile(`<[^>]*>`)
+
+// Tokenize splits text into lowercased words, html tags and entities are stripped
+func Tokenize(s string) []string {
+	s = html.UnescapeString(reTags.ReplaceAllString(s, " "))
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	res := words[:0]
+	for _, w := range words {
+		if len([]rune(w)) < 2 && !unicode.IsDigit([]rune(w)[0]) {
+			continue
+		}
+		res = append(res, w)
+	}
+	return res
+}
+
+// Exists checks if index was ever built
+func Exists(tx *bolt.Tx) bool {
+	return tx.Bucket([]byte(RootBucket)) != nil
+}
+
+// Add indexes item stored in fmFeed bucket with the key
+func Add(tx *bolt.Tx, fmFeed string, key []byte, item feed.Item) error {
+	root, err := tx.CreateBucketIfNotExists([]byte(RootBucket))
+	if err != nil {
+		return err
+	}
+	terms, err := root.CreateBucketIfNotExists([]byte(termsBucket))
+	if err != nil {
+		return err
+	}
+	docs, err := root.CreateBucketIfNotExists([]byte(docsBucket))
+	if err != nil {
+		return err
+	}
+
+	positions := map[string][]uint64{}
+	addField := func(f field, text string) {
+		for i, w := range Tokenize(text) {
+			positions[w] = append(positions[w], uint64(f)<<fieldShift|uint64(i))
+		}
+	}
+	addField(fieldTitle, item.Title)
+	addField(fieldAuthor, item.Author)
+	addField(fieldSource, item.Source)
+	addField(fieldDescription, string(item.Description))
+	if item.Content != item.Description {
+		addField(fieldContent, string(item.Content))
+	}
+
+	id := docID(fmFeed, key)
+	termsList := make([]string, 0, len(positions))
+	for term, pp := range positions {
+		tb, e := terms.CreateBucketIfNotExists([]byte(term))
+		if e != nil {
+			return errors.Wrapf(e, "can't make term bucket for %q", term)
+		}
+		if e = tb.Put(id, encodePositions(pp)); e != nil {
+			return e
+		}
+		termsList = append(termsList, term)
+	}
+	return docs.Put(id, []byte(strings.Join(termsList, "\n")))
+}
+
+// Remove deletes item from the index
+func Remove(tx *bolt.Tx, fmFeed string, key []byte) error {
+	root := tx.Bucket([]byte(RootBucket))
+	if root == nil {
+		return nil
+	}
+	terms, docs := root.Bucket([]byte(termsBucket)), root.Bucket([]byte(docsBucket))
+	if terms == nil || docs == nil {
+		return nil
+	}
+
+	id := docID(fmFeed, key)
+	termsList := docs.Get(id)
+	if termsList == nil {
+		return nil
+	}
+	for _, term := range bytes.Split(termsList, []byte("\n")) {
+		tb := terms.Bucket(term)
+		if tb == nil {
+			continue
+		}
+		if err := tb.Delete(id); err != nil {
+			return err
+		}
+		if k, _ := tb.Cursor().First(); k == nil {
+			if err := terms.DeleteBucket(term); err != nil {
+				return err
+			}
+		}
+	}
+	return docs.Delete(id)
+}
+
+// docID makes unique document id from feed name and item key
+func docID(fmFeed string, key []byte) []byte {
+	res := make([]byte, 0, len(fmFeed)+len(key)+1)
+	res = append(res, fmFeed...)
+	res = append(res, 0)
+	return append(res, key...)
+}
+
+func splitDocID(id []byte) (fmFeed string, key []byte) {
+	i := bytes.IndexByte(id, 0)
+	if i < 0 {
+		return "", id
+	}
+	return string(id[:i]), id[i+1:]
+}
+
+func encodePositions(pp []uint64) []byte {
+	res := make([]byte, 0, len(pp)*2)
+	prev := uint64(0)
+	for _, p := range pp {
+		res = binary.AppendUvarint(res, p-prev)
+		prev = p
+	}
+	return res
+}
+
+func decodePositions(data []byte) []uint64 {
+	var res []uint64
+	prev := uint64(0)
+	for len(data) > 0 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			break
+		}
+		prev += v
+		res = append(res, prev)
+		data = data[n:]
+	}
+	return res
+}
@@ -0,0 +1,232 @@
+package search
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+// Query defines search request
+type Query struct {
+	Terms   []string   // all terms must be present
+	Phrases [][]string // all phrases must be present as consecutive terms
+	Feeds   []string   // limit to feeds, all if empty
+	From    time.Time  // published at or after, ignored if zero
+	To      time.Time  // published before, ignored if zero
+	Limit   int
+}
+
+// Hit is a single matched document
+type Hit struct {
+	Feed  string
+	Key   []byte
+	Score int
+}
+
+// Result is a single item found by search
+type Result struct {
+	Feed  string    `json:"feed"`
+	Score int       `json:"score"`
+	Item  feed.Item `json:"item"`
+}
+
+// ParseQuery makes query from the text, quoted parts are phrases, the rest are terms
+func ParseQuery(text string) Query {
+	res := Query{}
+	parts := strings.Split(text, `"`)
+	for i, part := range parts {
+		tokens := Tokenize(part)
+		if len(tokens) == 0 {
+			continue
+		}
+		// odd parts are inside quotes, unless the closing quote is missing
+		if i%2 == 1 && i < len(parts)-1 && len(tokens) > 1 {
+			res.Phrases = append(res.Phrases, tokens)
+			continue
+		}
+		res.Terms = append(res.Terms, tokens...)
+	}
+	return res
+}
+
+// Empty checks if query has nothing to search for
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0
+}
+
+// ParseDate parses date in one of supported formats, used for From and To
+func ParseDate(s string) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339, s); err == nil {
+		return ts, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// ParseEndDate parses date like ParseDate for exclusive To, date without time is the end of that day
+func ParseEndDate(s string) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339, s); err == nil {
+		return ts, nil
+	}
+	ts, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return ts, err
+	}
+	return ts.AddDate(0, 0, 1), nil
+}
+
+// Search finds documents matching all terms and phrases of the query, sorted by score and recency
+func Search(tx *bolt.Tx, q Query) []Hit {
+	if q.Empty() || !Exists(tx) {
+		return nil
+	}
+	terms := tx.Bucket([]byte(RootBucket)).Bucket([]byte(termsBucket))
+	if terms == nil {
+		return nil
+	}
+
+	// docs maps docID to accumulated score, nil means no constraint applied yet
+	var docs map[string]int
+	intersect := func(matched map[string]int) {
+		if docs == nil {
+			docs = matched
+			return
+		}
+		for id, score := range docs {
+			if m, ok := matched[id]; ok {
+				docs[id] = score + m
+				continue
+			}
+			delete(docs, id)
+		}
+	}
+
+	for _, term := range q.Terms {
+		matched := map[string]int{}
+		if tb := terms.Bucket([]byte(term)); tb != nil {
+			_ = tb.ForEach(func(id, v []byte) error {
+				if q.accept(id) {
+					matched[string(id)] = score(decodePositions(v))
+				}
+				return nil
+			})
+		}
+		intersect(matched)
+	}
+
+	for _, phrase := range q.Phrases {
+		intersect(matchPhrase(terms, phrase, q.accept))
+	}
+
+	res := make([]Hit, 0, len(docs))
+	for id, sc := range docs {
+		fmFeed, key := splitDocID([]byte(id))
+		res = append(res, Hit{Feed: fmFeed, Key: key, Score: sc})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return keyTime(res[i].Key) > keyTime(res[j].Key)
+	})
+	if q.Limit > 0 && len(res) > q.Limit {
+		res = res[:q.Limit]
+	}
+	return res
+}
+
+// matchPhrase finds docs with all phrase terms at consecutive positions
+func matchPhrase(terms *bolt.Bucket, phrase []string, accept func(id []byte) bool) map[string]int {
+	res := map[string]int{}
+	first := terms.Bucket([]byte(phrase[0]))
+	if first == nil {
+		return res
+	}
+	_ = first.ForEach(func(id, v []byte) error {
+		if !accept(id) {
+			return nil
+		}
+		starts := decodePositions(v)
+		for i, term := range phrase[1:] {
+			tb := terms.Bucket([]byte(term))
+			if tb == nil {
+				return nil
+			}
+			pp := tb.Get(id)
+			if pp == nil {
+				return nil
+			}
+			next := map[uint64]bool{}
+			for _, p := range decodePositions(pp) {
+				next[p] = true
+			}
+			kept := starts[:0]
+			for _, s := range starts {
+				if next[s+uint64(i)+1] {
+					kept = append(kept, s)
+				}
+			}
+			if starts = kept; len(starts) == 0 {
+				return nil
+			}
+		}
+		res[string(id)] = score(starts) * len(phrase)
+		return nil
+	})
+	return res
+}
+
+// accept checks feed and date limits for the doc
+func (q Query) accept(id []byte) bool {
+	fmFeed, key := splitDocID(id)
+	if len(q.Feeds) > 0 {
+		found := false
+		for _, f := range q.Feeds {
+			if f == fmFeed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.From.IsZero() && q.To.IsZero() {
+		return true
+	}
+	ts := keyTime(key)
+	if !q.From.IsZero() && ts < q.From.Unix() {
+		return false
+	}
+	if !q.To.IsZero() && ts >= q.To.Unix() {
+		return false
+	}
+	return true
+}
+
+// score sums field weights of all positions
+func score(pp []uint64) int {
+	res := 0
+	for _, p := range pp {
+		res += fieldWeight[field(p>>fieldShift)]
+	}
+	return res
+}
+
+// keyTime extracts unix timestamp from the item key, see proc.BoltDB.Save for the key format
+func keyTime(key []byte) int64 {
+	i := bytes.IndexByte(key, '-')
+	if i < 0 {
+		return 0
+	}
+	ts, err := strconv.ParseInt(string(key[:i]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return ts
+}
@@ -0,0 +1,206 @@
+package search
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/umputun/feed-master/app/feed"
+)
+
+func testDB(t *testing.T) *bolt.DB {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// key makes item key like the store, unix time of the item first
+func key(ts int64, id string) []byte {
+	return []byte(fmt.Sprintf("%d-%s", ts, id))
+}
+
+func TestTokenize(t *testing.T) {
+	tbl := []struct {
+		text string
+		want []string
+	}{
+		{"Go 1.23 Released!", []string{"go", "1", "23", "released"}},
+		{"<p>Range over <b>function</b> iterators &amp; more</p>", []string{"range", "over", "function", "iterators", "more"}},
+		{"a b c 7", []string{"7"}},
+		{"Привет, мир", []string{"привет", "мир"}},
+		{"", []string{}},
+	}
+	for _, tt := range tbl {
+		if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tbl := []struct {
+		text string
+		want Query
+	}{
+		{"go generics", Query{Terms: []string{"go", "generics"}}},
+		{`"range over func" iterators`, Query{Terms: []string{"iterators"}, Phrases: [][]string{{"range", "over", "func"}}}},
+		{`"single" word`, Query{Terms: []string{"single", "word"}}},
+		{`unclosed "quote here`, Query{Terms: []string{"unclosed", "quote", "here"}}},
+		{`"" ,.`, Query{}},
+	}
+	for _, tt := range tbl {
+		got := ParseQuery(tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+		if got.Empty() != tt.want.Empty() {
+			t.Errorf("Empty() = %v for %q", got.Empty(), tt.text)
+		}
+	}
+}
+
+func TestParseDates(t *testing.T) {
+	tbl := []struct {
+		s        string
+		from, to time.Time
+		err      bool
+	}{
+		{s: "2024-05-01", from: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+		{s: "2024-12-31", from: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), to: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{s: "2024-05-01T10:30:00Z", from: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC),
+			to: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{s: "01/05/2024", err: true},
+		{s: "", err: true},
+	}
+	for _, tt := range tbl {
+		from, err1 := ParseDate(tt.s)
+		to, err2 := ParseEndDate(tt.s)
+		if tt.err {
+			if err1 == nil || err2 == nil {
+				t.Errorf("no error for %q", tt.s)
+			}
+			continue
+		}
+		if err1 != nil || err2 != nil {
+			t.Fatalf("errors %v, %v for %q", err1, err2, tt.s)
+		}
+		if !from.Equal(tt.from) || !to.Equal(tt.to) {
+			t.Errorf("%q parsed to %s - %s, want %s - %s", tt.s, from, to, tt.from, tt.to)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db := testDB(t)
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC).Unix()
+	docs := []struct {
+		feed string
+		key  []byte
+		item feed.Item
+	}{
+		{"go", key(day, "title"), feed.Item{Title: "Generics in Go", Description: "type parameters"}},
+		{"go", key(day+60, "desc"), feed.Item{Title: "Release notes", Description: "<p>now with generics</p>"}},
+		{"go", key(day+120, "newer"), feed.Item{Title: "Other", Description: "generics again"}},
+		{"rust", key(day+86400, "rust"), feed.Item{Title: "Generics in Rust", Author: "ferris",
+			Description: "range over func is not here"}},
+		{"go", key(day+86400*2, "phrase"), feed.Item{Title: "Iterators", Description: "range over func in Go 1.23",
+			Content: "range over func in Go 1.23"}},
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, d := range docs {
+			if e := Add(tx, d.feed, d.key, d.item); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tbl := []struct {
+		name string
+		q    Query
+		want []string // keys in order
+	}{
+		{name: "title ranks first, then newer", q: Query{Terms: []string{"generics"}},
+			want: []string{string(key(day+86400, "rust")), string(key(day, "title")), string(key(day+120, "newer")),
+				string(key(day+60, "desc"))}},
+		{name: "all terms", q: Query{Terms: []string{"generics", "rust"}}, want: []string{string(key(day+86400, "rust"))}},
+		{name: "feed filter", q: Query{Terms: []string{"generics"}, Feeds: []string{"rust"}},
+			want: []string{string(key(day+86400, "rust"))}},
+		{name: "phrase", q: Query{Phrases: [][]string{{"range", "over", "func"}}},
+			want: []string{string(key(day+86400*2, "phrase")), string(key(day+86400, "rust"))}},
+		{name: "phrase in order only", q: Query{Phrases: [][]string{{"func", "over"}}}},
+		{name: "phrase with missing term", q: Query{Phrases: [][]string{{"range", "under"}}}},
+		{name: "author", q: Query{Terms: []string{"ferris"}}, want: []string{string(key(day+86400, "rust"))}},
+		{name: "dates", q: Query{Terms: []string{"generics"}, From: time.Unix(day+60, 0), To: time.Unix(day+86400, 0)},
+			want: []string{string(key(day+120, "newer")), string(key(day+60, "desc"))}},
+		{name: "limit", q: Query{Terms: []string{"generics"}, Limit: 1}, want: []string{string(key(day+86400, "rust"))}},
+		{name: "unknown term", q: Query{Terms: []string{"generics", "python"}}},
+		{name: "empty", q: Query{}},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			_ = db.View(func(tx *bolt.Tx) error {
+				for _, h := range Search(tx, tt.q) {
+					got = append(got, string(h.Key))
+				}
+				return nil
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	db := testDB(t)
+	k1, k2 := key(100, "a"), key(200, "b")
+	err := db.Update(func(tx *bolt.Tx) error {
+		if e := Add(tx, "f1", k1, feed.Item{Title: "shared unique1"}); e != nil {
+			return e
+		}
+		if e := Add(tx, "f1", k2, feed.Item{Title: "shared unique2"}); e != nil {
+			return e
+		}
+		if e := Remove(tx, "f1", k1); e != nil {
+			return e
+		}
+		return Remove(tx, "f1", key(300, "missing"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(RootBucket))
+		terms := root.Bucket([]byte(termsBucket))
+		if terms.Bucket([]byte("unique1")) != nil {
+			t.Error("term of removed item kept")
+		}
+		if tb := terms.Bucket([]byte("shared")); tb == nil || tb.Get(docID("f1", k1)) != nil || tb.Get(docID("f1", k2)) == nil {
+			t.Error("bad postings of shared term")
+		}
+		if root.Bucket([]byte(docsBucket)).Get(docID("f1", k1)) != nil {
+			t.Error("doc of removed item kept")
+		}
+		if hits := Search(tx, Query{Terms: []string{"shared"}}); len(hits) != 1 || string(hits[0].Key) != string(k2) {
+			t.Errorf("got %+v", hits)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}